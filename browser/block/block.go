@@ -0,0 +1,539 @@
+// Package block implements request blocking and element hiding
+// with filter lists in Adblock Plus syntax, e.g. EasyList.
+package block
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/psilva261/mycel/logger"
+	"golang.org/x/net/publicsuffix"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Type of the requested resource
+type Type int
+
+const (
+	Document Type = 1 << iota
+	Script
+	Image
+	Stylesheet
+	XHR
+	Other
+
+	all = Document | Script | Image | Stylesheet | XHR | Other
+)
+
+// builtin rules which are always active
+var builtin = []string{
+	"adsense",
+	"adsystem",
+	"adservice",
+	"googletagservice",
+	"googletagmanager",
+	"script.ioam.de",
+	"googlesyndication",
+	"adserver",
+	"nativeads",
+	"prebid",
+	".ads.",
+	"google-analytics.com",
+}
+
+var (
+	mu  sync.RWMutex
+	def = New()
+)
+
+func init() {
+	def.Load(strings.NewReader(strings.Join(builtin, "\n")))
+}
+
+// List of filter rules
+type List struct {
+	block      rules
+	exceptions rules
+	hide       []*hide
+	unhide     []*hide
+}
+
+type rules struct {
+	byHost  map[string][]*rule
+	generic []*rule
+}
+
+type rule struct {
+	re         *regexp.Regexp
+	lit        string // literal which must be contained in the url
+	matchCase  bool
+	types      Type
+	thirdParty int // 1: only third-party, -1: only first-party
+	domains    []string
+	notDomains []string
+	elemHide   bool // exception disables element hiding
+}
+
+type hide struct {
+	sel        string
+	key        string // leading #id or .class
+	domains    []string
+	notDomains []string
+}
+
+func New() *List {
+	return &List{
+		block:      rules{byHost: make(map[string][]*rule)},
+		exceptions: rules{byHost: make(map[string][]*rule)},
+	}
+}
+
+// LoadDir loads all .txt files in dir into the default list
+func LoadDir(dir string) (err error) {
+	fns, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return fmt.Errorf("glob: %w", err)
+	}
+	for _, fn := range fns {
+		f, err := os.Open(fn)
+		if err != nil {
+			return fmt.Errorf("open: %w", err)
+		}
+		err = Load(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("load %v: %w", fn, err)
+		}
+	}
+	return
+}
+
+// Load filter rules into the default list
+func Load(r io.Reader) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return def.Load(r)
+}
+
+// Blocked checks u with the default list
+func Blocked(u *url.URL, t Type, origin *url.URL) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return def.Blocked(u, t, origin)
+}
+
+// Selectors of elements to hide on host with the default list
+func Selectors(host string, has func(key string) bool) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return def.Selectors(host, has)
+}
+
+// Load filter rules, one per line. Unsupported rules are skipped.
+func (l *List) Load(r io.Reader) (err error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if l.loadHide(line) {
+			continue
+		}
+		exception := strings.HasPrefix(line, "@@")
+		if exception {
+			line = line[2:]
+		}
+		ru, host, err := parseRule(line)
+		if err != nil {
+			log.Printf("block: skip %v: %v", line, err)
+			continue
+		}
+		rs := &l.block
+		if exception {
+			rs = &l.exceptions
+		} else if ru.elemHide {
+			continue
+		}
+		if host != "" {
+			rs.byHost[host] = append(rs.byHost[host], ru)
+		} else {
+			rs.generic = append(rs.generic, ru)
+		}
+	}
+	return sc.Err()
+}
+
+// loadHide parses element hiding rules like example.com##.ad
+func (l *List) loadHide(line string) (ok bool) {
+	i := strings.Index(line, "#")
+	if i < 0 {
+		return false
+	}
+	rest := line[i:]
+	var unhide bool
+	switch {
+	case strings.HasPrefix(rest, "##"):
+		rest = rest[2:]
+	case strings.HasPrefix(rest, "#@#"):
+		rest = rest[3:]
+		unhide = true
+	case strings.HasPrefix(rest, "#?#"), strings.HasPrefix(rest, "#$#"), strings.HasPrefix(rest, "#%#"):
+		// extended syntax is not supported
+		return true
+	default:
+		return false
+	}
+	if rest == "" || strings.HasPrefix(rest, "+js") || strings.HasPrefix(rest, "^") {
+		return true
+	}
+	h := &hide{
+		sel: rest,
+		key: key(rest),
+	}
+	h.domains, h.notDomains = domains(line[:i], ",")
+	if unhide {
+		l.unhide = append(l.unhide, h)
+	} else {
+		l.hide = append(l.hide, h)
+	}
+	return true
+}
+
+// key is the leading #id or .class of a simple selector
+func key(sel string) string {
+	if len(sel) < 2 || (sel[0] != '#' && sel[0] != '.') {
+		return ""
+	}
+	i := 1
+	for i < len(sel) && (sel[i] == '-' || sel[i] == '_' || 'a' <= sel[i] && sel[i] <= 'z' || 'A' <= sel[i] && sel[i] <= 'Z' || '0' <= sel[i] && sel[i] <= '9') {
+		i++
+	}
+	if i < len(sel) && sel[i] == ',' {
+		// selector list
+		return ""
+	}
+	return sel[:i]
+}
+
+func domains(s, sep string) (ds, notDs []string) {
+	for _, d := range strings.Split(s, sep) {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if strings.HasPrefix(d, "~") {
+			notDs = append(notDs, d[1:])
+		} else if d != "" {
+			ds = append(ds, d)
+		}
+	}
+	return
+}
+
+func parseRule(line string) (ru *rule, host string, err error) {
+	ru = &rule{}
+	pattern := line
+	if i := strings.LastIndex(line, "$"); i >= 0 && !strings.HasSuffix(line, "/") {
+		pattern = line[:i]
+		if err = ru.options(line[i+1:]); err != nil {
+			return nil, "", err
+		}
+	}
+	if ru.types == 0 {
+		ru.types = all &^ Document
+	}
+	if ru.re, ru.lit, err = compile(pattern, ru.matchCase); err != nil {
+		return nil, "", err
+	}
+	if strings.HasPrefix(pattern, "||") {
+		host = pattern[2:]
+		if i := strings.IndexAny(host, "^/*|$:"); i >= 0 {
+			host = host[:i]
+		}
+		if !strings.Contains(host, ".") {
+			host = ""
+		}
+		host = strings.ToLower(host)
+	}
+	return
+}
+
+func (ru *rule) options(opts string) (err error) {
+	var types, notTypes Type
+	for _, o := range strings.Split(opts, ",") {
+		neg := strings.HasPrefix(o, "~")
+		o = strings.TrimPrefix(o, "~")
+		var t Type
+		switch o {
+		case "script":
+			t = Script
+		case "image":
+			t = Image
+		case "stylesheet":
+			t = Stylesheet
+		case "xmlhttprequest", "xhr":
+			t = XHR
+		case "document", "doc":
+			t = Document
+		case "subdocument", "object", "media", "font", "ping", "websocket", "other":
+			t = Other
+		case "all":
+			t = all
+		case "third-party", "3p":
+			ru.thirdParty = 1
+			if neg {
+				ru.thirdParty = -1
+			}
+			continue
+		case "first-party", "1p":
+			ru.thirdParty = -1
+			if neg {
+				ru.thirdParty = 1
+			}
+			continue
+		case "match-case":
+			ru.matchCase = true
+			continue
+		case "elemhide", "ehide", "generichide", "ghide":
+			ru.elemHide = true
+			continue
+		case "important":
+			continue
+		default:
+			if strings.HasPrefix(o, "domain=") {
+				ru.domains, ru.notDomains = domains(o[len("domain="):], "|")
+				continue
+			}
+			return fmt.Errorf("unsupported option %v", o)
+		}
+		if neg {
+			notTypes |= t
+		} else {
+			types |= t
+		}
+	}
+	if types == 0 && notTypes != 0 {
+		types = all &^ Document
+	}
+	ru.types = types &^ notTypes
+	return
+}
+
+// compile the pattern into a regular expression
+func compile(p string, matchCase bool) (re *regexp.Regexp, lit string, err error) {
+	var expr strings.Builder
+	if !matchCase {
+		expr.WriteString("(?i)")
+	}
+	if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+		expr.WriteString(p[1 : len(p)-1])
+		re, err = regexp.Compile(expr.String())
+		return
+	}
+	if strings.HasPrefix(p, "||") {
+		expr.WriteString(`^[a-z][a-z0-9+.-]*://([^/?#]*\.)?`)
+		p = p[2:]
+	} else if strings.HasPrefix(p, "|") {
+		expr.WriteString("^")
+		p = p[1:]
+	}
+	end := strings.HasSuffix(p, "|")
+	if end {
+		p = p[:len(p)-1]
+	}
+	var cur strings.Builder
+	endLit := func() {
+		if cur.Len() > len(lit) {
+			lit = cur.String()
+		}
+		cur.Reset()
+	}
+	for _, c := range p {
+		switch c {
+		case '*':
+			endLit()
+			expr.WriteString(".*")
+		case '^':
+			endLit()
+			expr.WriteString(`(?:[^a-zA-Z0-9_.%-]|$)`)
+		default:
+			cur.WriteRune(c)
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	endLit()
+	if end {
+		expr.WriteString("$")
+	}
+	if !matchCase {
+		lit = strings.ToLower(lit)
+	}
+	re, err = regexp.Compile(expr.String())
+	return
+}
+
+// Blocked if a rule matches u of type t requested by a page from
+// origin and no exception rule matches. origin can be nil.
+func (l *List) Blocked(u *url.URL, t Type, origin *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	r := &req{
+		s:    u.String(),
+		host: strings.ToLower(u.Hostname()),
+		t:    t,
+	}
+	r.lower = strings.ToLower(r.s)
+	if origin != nil {
+		r.origin = strings.ToLower(origin.Hostname())
+		r.thirdParty = site(r.host) != site(r.origin)
+		// exceptions for the whole page
+		or := &req{
+			s:     origin.String(),
+			lower: strings.ToLower(origin.String()),
+			host:  r.origin,
+			t:     Document,
+		}
+		if l.exceptions.match(or, func(ru *rule) bool { return ru.types&Document != 0 }) {
+			return false
+		}
+	}
+	if !l.block.match(r, nil) {
+		return false
+	}
+	return !l.exceptions.match(r, nil)
+}
+
+type req struct {
+	s, lower   string
+	host       string
+	origin     string
+	thirdParty bool
+	t          Type
+}
+
+func site(host string) string {
+	s, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return s
+}
+
+func (rs rules) match(r *req, filter func(*rule) bool) bool {
+	for h := r.host; h != ""; {
+		for _, ru := range rs.byHost[h] {
+			if (filter == nil || filter(ru)) && ru.matches(r) {
+				return true
+			}
+		}
+		i := strings.Index(h, ".")
+		if i < 0 {
+			break
+		}
+		h = h[i+1:]
+	}
+	for _, ru := range rs.generic {
+		if (filter == nil || filter(ru)) && ru.matches(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ru *rule) matches(r *req) bool {
+	if ru.types&r.t == 0 {
+		return false
+	}
+	if ru.thirdParty == 1 && !r.thirdParty || ru.thirdParty == -1 && r.thirdParty {
+		return false
+	}
+	if len(ru.domains) > 0 || len(ru.notDomains) > 0 {
+		if r.origin == "" || !onDomains(r.origin, ru.domains, ru.notDomains) {
+			return false
+		}
+	}
+	if ru.matchCase {
+		if !strings.Contains(r.s, ru.lit) {
+			return false
+		}
+	} else if !strings.Contains(r.lower, ru.lit) {
+		return false
+	}
+	return ru.re.MatchString(r.s)
+}
+
+// onDomains is true if host is (a subdomain of) one of ds
+// and none of notDs. Empty ds match all hosts.
+func onDomains(host string, ds, notDs []string) bool {
+	for _, d := range notDs {
+		if isSubdomain(host, d) {
+			return false
+		}
+	}
+	if len(ds) == 0 {
+		return true
+	}
+	for _, d := range ds {
+		if isSubdomain(host, d) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSubdomain(host, d string) bool {
+	return host == d || strings.HasSuffix(host, "."+d)
+}
+
+// Selectors of elements to hide on host. Selectors with a leading
+// #id or .class are only returned if has(key) is true.
+func (l *List) Selectors(host string, has func(key string) bool) (sels []string) {
+	host = strings.ToLower(host)
+	if l.exceptions.match(&req{
+		s:     "http://" + host + "/",
+		lower: "http://" + host + "/",
+		host:  host,
+		t:     Other,
+	}, func(ru *rule) bool { return ru.elemHide }) {
+		return
+	}
+	unhidden := make(map[string]bool)
+	for _, h := range l.unhide {
+		if onDomains(host, h.domains, h.notDomains) {
+			unhidden[h.sel] = true
+		}
+	}
+	seen := make(map[string]bool)
+	for _, h := range l.hide {
+		if unhidden[h.sel] || seen[h.sel] || !onDomains(host, h.domains, h.notDomains) {
+			continue
+		}
+		if h.key != "" && has != nil && !has(h.key) {
+			continue
+		}
+		seen[h.sel] = true
+		sels = append(sels, h.sel)
+	}
+	sort.Strings(sels)
+	return
+}
+
+// TypeOf guesses the resource type from the file extension
+func TypeOf(u *url.URL) Type {
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".js", ".mjs":
+		return Script
+	case ".css":
+		return Stylesheet
+	case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".ico", ".bmp":
+		return Image
+	}
+	return Other
+}
@@ -0,0 +1,113 @@
+package block
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const filters = `[Adblock Plus 2.0]
+! comment
+||ads.example.com^
+/banner/*/img^$image
+||tracker.net^$third-party
+||cdn.example.org/lib.js$script,domain=foo.com|~bar.foo.com
+@@||ads.example.com/allowed^
+@@||trusted.com^$document
+|https://start.example.net/
+.swf|
+##.ad-box
+##div[id^="sponsor"]
+example.com###top-banner
+example.com#@#.ad-box
+@@||quiet.org^$elemhide
+||popup.com^$popup
+`
+
+func list(t *testing.T) *List {
+	l := New()
+	if err := l.Load(strings.NewReader(filters)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return l
+}
+
+func TestBlocked(t *testing.T) {
+	l := list(t)
+	tests := []struct {
+		u      string
+		t      Type
+		origin string
+		exp    bool
+	}{
+		{"https://ads.example.com/x.js", Script, "https://example.com", true},
+		{"https://sub.ads.example.com/x.js", Script, "", true},
+		{"https://ads.example.com.evil.net/", Script, "", false},
+		{"https://ads.example.com/allowed/x.js", Script, "", false},
+		{"https://ads.example.com/x.js", Script, "https://trusted.com/page", false},
+		{"https://ads.example.com/", Document, "", false},
+		{"https://example.com/banner/1/img?id=2", Image, "", true},
+		{"https://example.com/banner/1/img?id=2", Script, "", false},
+		{"https://tracker.net/t.gif", Image, "https://example.com", true},
+		{"https://tracker.net/t.gif", Image, "https://www.tracker.net", false},
+		{"https://cdn.example.org/lib.js", Script, "https://www.foo.com", true},
+		{"https://cdn.example.org/lib.js", Script, "https://bar.foo.com", false},
+		{"https://cdn.example.org/lib.js", Script, "https://baz.com", false},
+		{"https://start.example.net/a", Other, "", true},
+		{"http://start.example.net/a", Other, "", false},
+		{"https://example.com/movie.swf", Other, "", true},
+		{"https://example.com/movie.swf?x", Other, "", false},
+		{"https://popup.com/", Other, "", false},
+		{"https://adsense.com/", Other, "", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.u)
+		var o *url.URL
+		if tt.origin != "" {
+			o, _ = url.Parse(tt.origin)
+		}
+		if b := l.Blocked(u, tt.t, o); b != tt.exp {
+			t.Errorf("%v %v %v: %v", tt.u, tt.t, tt.origin, b)
+		}
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	u, _ := url.Parse("https://pagead2.googlesyndication.com/pagead/show_ads.js")
+	if !Blocked(u, Script, nil) {
+		t.Fail()
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	l := list(t)
+	has := func(k string) bool { return k != ".missing" }
+	tests := map[string]string{
+		"example.com":   `#top-banner, div[id^="sponsor"]`,
+		"a.example.com": `#top-banner, div[id^="sponsor"]`,
+		"other.com":     `.ad-box, div[id^="sponsor"]`,
+		"quiet.org":     ``,
+	}
+	for host, exp := range tests {
+		if res := strings.Join(l.Selectors(host, has), ", "); res != exp {
+			t.Errorf("%v: %v", host, res)
+		}
+	}
+	if sels := l.Selectors("other.com", func(string) bool { return false }); len(sels) != 1 {
+		t.Errorf("%+v", sels)
+	}
+}
+
+func TestTypeOf(t *testing.T) {
+	for s, exp := range map[string]Type{
+		"https://a.com/x.js?v=1": Script,
+		"https://a.com/s.CSS":    Stylesheet,
+		"https://a.com/i.png":    Image,
+		"https://a.com/":         Other,
+	} {
+		u, _ := url.Parse(s)
+		if typ := TypeOf(u); typ != exp {
+			t.Errorf("%v: %v", s, typ)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package bookmarks stores bookmarks in a plain text file with
+// one URL and title per line:
+//
+// https://9p.io/plan9/ Plan 9 from Bell Labs
+package bookmarks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// URL of the generated bookmarks page
+const URL = "about:bookmarks"
+
+type Bookmark struct {
+	URL   string
+	Title string
+}
+
+func (b Bookmark) String() string {
+	if b.Title == "" {
+		return b.URL
+	}
+	return b.URL + " " + b.Title
+}
+
+var (
+	mu  sync.RWMutex
+	bms []Bookmark
+	fn  string
+)
+
+// SetFile loads the bookmarks from f, new ones are appended there
+func SetFile(f string) (err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	fn = f
+	bms = nil
+	r, err := os.Open(f)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer r.Close()
+	bms, err = parse(r)
+	return
+}
+
+// All bookmarks in the order they were added
+func All() []Bookmark {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Bookmark{}, bms...)
+}
+
+// Add bookmark unless its URL is already bookmarked
+func Add(u, title string) error {
+	return Load(strings.NewReader(Bookmark{u, oneLine(title)}.String()))
+}
+
+// Load bookmarks from r in the file format and add them
+func Load(r io.Reader) (err error) {
+	add, err := parse(r)
+	if err != nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var buf bytes.Buffer
+	for _, b := range add {
+		if has(b.URL) {
+			continue
+		}
+		bms = append(bms, b)
+		fmt.Fprintf(&buf, "%v\n", b)
+	}
+	if fn == "" || buf.Len() == 0 {
+		return
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	return f.Close()
+}
+
+func has(u string) bool {
+	for _, b := range bms {
+		if b.URL == u {
+			return true
+		}
+	}
+	return false
+}
+
+func parse(r io.Reader) (res []Bookmark, err error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		u, t, _ := strings.Cut(l, " ")
+		res = append(res, Bookmark{u, strings.TrimSpace(t)})
+	}
+	return res, sc.Err()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Text of the bookmarks in the file format
+func Text() []byte {
+	var buf bytes.Buffer
+	for _, b := range All() {
+		fmt.Fprintf(&buf, "%v\n", b)
+	}
+	return buf.Bytes()
+}
+
+// HTML page listing the bookmarks
+func HTML() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n<html><head><title>Bookmarks</title></head><body>\n<h1>Bookmarks</h1>\n<ul>\n")
+	for _, b := range All() {
+		t := b.Title
+		if t == "" {
+			t = b.URL
+		}
+		fmt.Fprintf(&buf, "<li><a href=\"%v\">%v</a></li>\n", html.EscapeString(b.URL), html.EscapeString(t))
+	}
+	buf.WriteString("</ul>\n</body></html>\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,39 @@
+package bookmarks
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	fn := t.TempDir() + "/bookmarks"
+	if err := os.WriteFile(fn, []byte("https://9p.io/plan9/ Plan 9 from Bell Labs\n"), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := SetFile(fn); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := Add("https://example.com/?a=1&b=2", "Example\n<Domain>"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := Add("https://9p.io/plan9/", "Again"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := Load(strings.NewReader("https://go.dev\n")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	exp := "https://9p.io/plan9/ Plan 9 from Bell Labs\nhttps://example.com/?a=1&b=2 Example <Domain>\nhttps://go.dev\n"
+	if string(buf) != exp || string(Text()) != exp {
+		t.Fatalf("%q", buf)
+	}
+	h := string(HTML())
+	if !strings.Contains(h, `<a href="https://example.com/?a=1&amp;b=2">Example &lt;Domain&gt;</a>`) ||
+		!strings.Contains(h, `<a href="https://go.dev">https://go.dev</a>`) {
+		t.Fatalf("%v", h)
+	}
+}
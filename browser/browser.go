@@ -2,30 +2,45 @@ package browser
 
 import (
 	"9fans.net/go/draw"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/psilva261/mycel"
+	"github.com/psilva261/mycel/browser/block"
+	"github.com/psilva261/mycel/browser/bookmarks"
 	"github.com/psilva261/mycel/browser/cache"
+	"github.com/psilva261/mycel/browser/cookies"
+	"github.com/psilva261/mycel/browser/downloads"
 	"github.com/psilva261/mycel/browser/duitx"
+	"github.com/psilva261/mycel/browser/file"
 	"github.com/psilva261/mycel/browser/fs"
+	"github.com/psilva261/mycel/browser/gemini"
+	"github.com/psilva261/mycel/browser/gopher"
 	"github.com/psilva261/mycel/browser/history"
+	"github.com/psilva261/mycel/browser/perm"
+	"github.com/psilva261/mycel/browser/webfs"
+	"github.com/psilva261/mycel/browser/zoom"
 	"github.com/psilva261/mycel/img"
 	"github.com/psilva261/mycel/js"
 	"github.com/psilva261/mycel/logger"
 	"github.com/psilva261/mycel/nodes"
 	"github.com/psilva261/mycel/style"
 	"golang.org/x/net/html"
-	"golang.org/x/net/publicsuffix"
 	"image"
+	"io"
 	"io/ioutil"
 	"math"
+	"mime/multipart"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 	"unicode"
 
 	"github.com/mjl-/duit"
@@ -35,6 +50,13 @@ const (
 	EnterKey = 10
 
 	UserAgent = "mycel"
+
+	// DefaultUrl is loaded when there is neither a start page
+	// nor a previous session
+	DefaultUrl = "http://9p.io"
+
+	// firstScreenful of HTML that is shown before the rest has arrived
+	firstScreenful = 32 * 1024
 )
 
 var debugPrintHtml = false
@@ -59,28 +81,27 @@ var cursor = [16 * 2]uint8{
 }
 
 var (
-	ExperimentalJsInsecure bool
-	EnableNoScriptTag      bool
+	EnableNoScriptTag bool
+
+	// Webfs fetches http and https URLs through /mnt/web on Plan 9.
+	// Cookies are then handled by webfs(4) without the per-site
+	// permissions. Ignored on other systems.
+	Webfs bool
 )
 
 var (
-	Style    = style.Map{}
-	dui      *duit.DUI
-	scroller *duitx.Scroll
-	display  *draw.Display
-
-	selected  int
-	dragRect  draw.Rectangle
-	fromLabel *duitx.Label
+	Style   = style.Map{}
+	dui     *duit.DUI
+	display *draw.Display
 
 	colorCache = make(map[draw.Color]*draw.Image)
-	imageCache = make(map[string]*draw.Image)
 )
 
 type Label struct {
 	*duitx.Label
 
-	n *nodes.Node
+	n     *nodes.Node
+	color draw.Color
 }
 
 func NewLabel(t string, n *nodes.Node) *Label {
@@ -89,7 +110,8 @@ func NewLabel(t string, n *nodes.Node) *Label {
 			Text: t + " ",
 			Font: n.Font(),
 		},
-		n: n,
+		n:     n,
+		color: n.Map.Color(),
 	}
 }
 
@@ -121,7 +143,7 @@ func NewText(b *Browser, content []string, n *nodes.Node) (el []*Element) {
 }
 
 func (ui *Label) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
-	c := ui.n.Map.Color()
+	c := ui.color
 	i, ok := colorCache[c]
 	if !ok {
 		var err error
@@ -185,7 +207,7 @@ func newImage(b *Browser, n *nodes.Node) (ui duit.UI, err error) {
 	src := attr(*n.DomSubtree, "src")
 	log.Printf("newImage: src: %v", src)
 
-	if src == img.SrcZero {
+	if src == img.SrcZero || !b.perm().Images {
 		return
 	}
 
@@ -202,7 +224,7 @@ func newImage(b *Browser, n *nodes.Node) (ui duit.UI, err error) {
 			return nil, fmt.Errorf("serialize: %w", err)
 		}
 		log.Printf("newImage: xml: %v", xml)
-		i, err = img.Svg(dui, xml, dui.Scale(n.Width()), dui.Scale(n.Height()))
+		i, err = img.Svg(dui, xml, dui.Scale(n.Width()), dui.Scale(n.Height()), n.Zoom())
 		if err != nil {
 			return nil, fmt.Errorf("img svg %v: %v", xml, err)
 		}
@@ -218,16 +240,16 @@ func newImage(b *Browser, n *nodes.Node) (ui duit.UI, err error) {
 		return nil, fmt.Errorf("no src in %+v", n.DomSubtree.Attr)
 	}
 
-	if i, cached = imageCache[src]; !cached {
+	if i, cached = b.imageCache[src]; !cached {
 		mw, _ := n.CssPx("max-width")
 		mw = dui.Scale(mw)
 		w := dui.Scale(n.Width())
 		h := dui.Scale(n.Height())
-		i, err = img.Load(dui, b, src, mw, w, h, false)
+		i, err = img.Load(dui, b, src, mw, w, h, n.Zoom(), false)
 		if err != nil {
 			return nil, fmt.Errorf("load image: %w", err)
 		}
-		imageCache[src] = i
+		b.imageCache[src] = i
 	}
 
 img_elem:
@@ -304,8 +326,10 @@ type Element struct {
 	n       *nodes.Node
 	orig    image.Point
 	IsLink  bool
+	link    *url.URL
 	Click   func() duit.Event
 	Changed func(*Element)
+	reset   func()
 
 	m    draw.Mouse
 	rect image.Rectangle
@@ -447,6 +471,7 @@ func (el *Element) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig ima
 		el.UI.Draw(dui, self, img, orig, m, force)
 	}
 	el.orig = orig
+	el.drawKeys(dui, self, img, orig)
 }
 
 func (el *Element) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, force bool) {
@@ -535,9 +560,15 @@ func NewInputField(b *Browser, n *nodes.Node) *Element {
 	if n.Css("width") == "" && n.Css("max-width") == "" {
 		n.SetCss("max-width", "200px")
 	}
-	f := &duit.Field{
+	placeholder := attr(*n.DomSubtree, "placeholder")
+	if placeholder == "" && t == "date" {
+		placeholder = "yyyy-mm-dd"
+	}
+	def := attr(*n.DomSubtree, "value")
+	var f *duit.Field
+	f = &duit.Field{
 		Font:        n.Font(),
-		Placeholder: attr(*n.DomSubtree, "placeholder"),
+		Placeholder: placeholder,
 		Password:    t == "password",
 		Text:        attr(*n.DomSubtree, "value"),
 		Changed: func(t string) (e duit.Event) {
@@ -564,28 +595,41 @@ func NewInputField(b *Browser, n *nodes.Node) *Element {
 			return
 		},
 	}
-	return NewElement(b, f, n)
+	el := NewElement(b, f, n)
+	if el != nil {
+		el.reset = func() {
+			f.Text = def
+			f.Cursor1 = 0
+			f.SelectionStart1 = 0
+			setAttr(n.DomSubtree, "value", def)
+		}
+	}
+	return el
 }
 
 func NewSelect(b *Browser, n *nodes.Node) *Element {
 	var l *duit.List
+	var opts []*html.Node
 	l = &duit.List{
-		Values: make([]*duit.ListValue, 0, len(n.Children)),
-		Font:   n.Font(),
+		Values:   make([]*duit.ListValue, 0, len(n.Children)),
+		Font:     n.Font(),
+		Multiple: n.HasAttr("multiple"),
 		Changed: func(i int) (e duit.Event) {
-			v := l.Values[i]
-			vv := fmt.Sprintf("%v", v.Value)
-			if vv == "" {
-				vv = v.Text
+			for j, v := range l.Values {
+				if v.Selected {
+					setAttr(opts[j], "selected", "")
+				} else {
+					removeAttr(opts[j], "selected")
+				}
 			}
-			setAttr(n.DomSubtree, "value", vv)
 			e.Consumed = true
 			return
 		},
 	}
-	for _, c := range n.Children {
+	var defs []bool
+	n.Traverse(func(_ int, c *nodes.Node) {
 		if c.Data() != "option" {
-			continue
+			return
 		}
 		lv := &duit.ListValue{
 			Text:     c.ContentString(false),
@@ -593,14 +637,211 @@ func NewSelect(b *Browser, n *nodes.Node) *Element {
 			Selected: c.HasAttr("selected"),
 		}
 		l.Values = append(l.Values, lv)
-	}
+		opts = append(opts, c.DomSubtree)
+		defs = append(defs, lv.Selected)
+	})
 	if n.Css("width") == "" && n.Css("max-width") == "" {
 		n.SetCss("max-width", "200px")
 	}
 	if n.Css("height") == "" {
 		n.SetCss("height", fmt.Sprintf("%vpx", 4*n.Font().Height))
 	}
-	return NewElement(b, duit.NewScroll(l), n)
+	el := NewElement(b, duit.NewScroll(l), n)
+	if el != nil {
+		el.reset = func() {
+			for j, v := range l.Values {
+				v.Selected = defs[j]
+				if defs[j] {
+					setAttr(opts[j], "selected", "")
+				} else {
+					removeAttr(opts[j], "selected")
+				}
+			}
+		}
+	}
+	return el
+}
+
+func NewCheckbox(b *Browser, n *nodes.Node) *Element {
+	def := n.HasAttr("checked")
+	var cb *duit.Checkbox
+	cb = &duit.Checkbox{
+		Checked:  def,
+		Disabled: n.HasAttr("disabled"),
+		Font:     n.Font(),
+		Changed: func() (e duit.Event) {
+			setChecked(n.DomSubtree, cb.Checked)
+			e.Consumed = true
+			return
+		},
+	}
+	el := NewElement(b, cb, n)
+	if el != nil {
+		el.reset = func() {
+			cb.Checked = def
+			setChecked(n.DomSubtree, def)
+		}
+	}
+	return el
+}
+
+// radioKey identifies a group of radio buttons
+type radioKey struct {
+	form *nodes.Node
+	name string
+}
+
+func NewRadio(b *Browser, n *nodes.Node) *Element {
+	def := n.HasAttr("checked")
+	rb := &duit.Radiobutton{
+		Selected: def,
+		Disabled: n.HasAttr("disabled"),
+		Font:     n.Font(),
+		Value:    n.DomSubtree,
+		Changed: func(v interface{}) (e duit.Event) {
+			setChecked(v.(*html.Node), true)
+			e.Consumed = true
+			return
+		},
+	}
+	if nm := n.Attr("name"); nm != "" {
+		if b.radios == nil {
+			b.radios = make(map[radioKey]duit.RadiobuttonGroup)
+		}
+		k := radioKey{n.Ancestor("form"), nm}
+		g := append(b.radios[k], rb)
+		for _, r := range g {
+			r.Group = g
+		}
+		b.radios[k] = g
+	}
+	el := NewElement(b, rb, n)
+	if el != nil {
+		el.reset = func() {
+			rb.Selected = def
+			setChecked(n.DomSubtree, def)
+		}
+	}
+	return el
+}
+
+// setChecked updates the checked attribute. Other radio
+// buttons in the group are unchecked.
+func setChecked(n *html.Node, checked bool) {
+	if !checked {
+		removeAttr(n, "checked")
+		return
+	}
+	setAttr(n, "checked", "")
+	if attr(*n, "type") != "radio" || attr(*n, "name") == "" {
+		return
+	}
+	root := n
+	for p := n.Parent; p != nil; p = p.Parent {
+		root = p
+		if p.Type == html.ElementNode && p.Data == "form" {
+			break
+		}
+	}
+	var f func(c *html.Node)
+	f = func(c *html.Node) {
+		if c != n && c.Data == "input" && attr(*c, "type") == "radio" && attr(*c, "name") == attr(*n, "name") {
+			removeAttr(c, "checked")
+		}
+		for cc := c.FirstChild; cc != nil; cc = cc.NextSibling {
+			f(cc)
+		}
+	}
+	f(root)
+}
+
+// NewFileInput lets the user pick a file which is uploaded with
+// the form. The path is kept in b.files, a value attribute set by
+// the page is ignored.
+func NewFileInput(b *Browser, n *nodes.Node) *Element {
+	const choose = "Choose file..."
+	var btn *duit.Button
+	btn = &duit.Button{
+		Text: choose,
+		Font: n.Font(),
+		Click: func() (e duit.Event) {
+			e.Consumed = true
+			if b.PickFile == nil {
+				return
+			}
+			res := make(chan *string, 1)
+			b.PickFile(res)
+			go func() {
+				fn, ok := <-res
+				if !ok || fn == nil {
+					return
+				}
+				dui.Call <- func() {
+					if b.files == nil {
+						b.files = make(map[*html.Node]string)
+					}
+					b.files[n.DomSubtree] = *fn
+					btn.Text = choose
+					if *fn != "" {
+						btn.Text = filepath.Base(*fn)
+					}
+					b.redrawForm()
+				}
+			}()
+			return
+		},
+	}
+	el := NewElement(b, btn, n)
+	if el != nil {
+		el.reset = func() {
+			btn.Text = choose
+			delete(b.files, n.DomSubtree)
+		}
+	}
+	return el
+}
+
+func NewResetButton(b *Browser, n *nodes.Node) *Element {
+	t := n.Attr("value")
+	if t == "" {
+		t = strings.TrimSpace(n.ContentString(false))
+	}
+	if t == "" {
+		t = "Reset"
+	}
+	btn := &duit.Button{
+		Text: t,
+		Font: n.Font(),
+		Click: func() (e duit.Event) {
+			if f := n.Ancestor("form"); f != nil {
+				b.resetForm(f)
+			}
+			e.Consumed = true
+			return
+		},
+	}
+	return NewElement(b, btn, n)
+}
+
+// resetForm restores the initial values of the form's fields
+func (b *Browser) resetForm(f *nodes.Node) {
+	TraverseTree(b.Website.UI, func(ui duit.UI) {
+		el, ok := ui.(*Element)
+		if ok && el != nil && el.reset != nil && el.n.Ancestor("form") == f {
+			el.reset()
+		}
+	})
+	b.redrawForm()
+}
+
+// redrawForm after widgets changed outside of their event handlers
+func (b *Browser) redrawForm() {
+	if b.scroller != nil {
+		b.scroller.Redraw()
+	}
+	dui.MarkLayout(dui.Top.UI)
+	dui.MarkDraw(dui.Top.UI)
+	dui.Render()
 }
 
 func NewTextArea(b *Browser, n *nodes.Node) *Element {
@@ -620,6 +861,10 @@ func NewTextArea(b *Browser, n *nodes.Node) *Element {
 	}
 
 	el := NewElement(b, edit, n)
+	el.reset = func() {
+		edit.Replace(duit.Cursor{Start: 0, Cur: edit.Size()}, []byte(t))
+		n.SetText(t)
+	}
 	el.Changed = func(e *Element) {
 		ed := e.UI.(*duitx.Box).Kids[0].UI.(*duit.Edit)
 
@@ -652,7 +897,21 @@ func (el *Element) FlexDir() duitx.Dir {
 }
 
 func (el *Element) Key(dui *duit.DUI, self *duit.Kid, k rune, m draw.Mouse, orig image.Point) (r duit.Result) {
+	body := el.n != nil && el.n.Data() == "body" && el.b != nil
+	if body && k == draw.KeyCmd+'c' && el.b.selected > 0 {
+		dui.WriteSnarf([]byte(selectedText(el)))
+		r.Consumed = true
+		return
+	}
+	if body && el.keys(k) {
+		r.Consumed = true
+		return
+	}
 	r = el.UI.Key(dui, self, k, m, orig)
+	if body && !r.Consumed && k == HintKey {
+		el.b.showHints()
+		r.Consumed = true
+	}
 
 	if el.Changed != nil {
 		el.Changed(el)
@@ -674,6 +933,15 @@ func (el *Element) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw
 	if el == nil {
 		return
 	}
+	if m.Buttons == 4 && el.m.Buttons == 0 {
+		// innermost element with a menu shows it
+		r = el.UI.Mouse(dui, self, m, origM, orig)
+		el.m = m
+		if !r.Consumed && el.showMenu() {
+			r.Consumed = true
+		}
+		return
+	}
 
 	x := m.Point.X
 	y := m.Point.Y
@@ -682,7 +950,10 @@ func (el *Element) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw
 	border := 1 > x || x > (maxX-1) || 1 > y || y > (maxY-1)
 
 	if l, ok := el.UI.(*Label); ok && l != nil {
-		fromLabel = l.Label
+		el.b.fromLabel = l.Label
+	}
+	if m.Buttons != 0 && el.editable() {
+		el.b.editing = true
 	}
 	if el.n.Data() == "body" {
 		if el.mouseSelect(dui, self, m, origM, orig) {
@@ -694,6 +965,12 @@ func (el *Element) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw
 		return duit.Result{
 			Consumed: true,
 		}
+	} else if el.link != nil && el.m.Buttons&2 == 0 && m.Buttons == 2 && el.b.OpenTab != nil {
+		el.m = m
+		el.b.OpenTab(el.link)
+		return duit.Result{
+			Consumed: true,
+		}
 	}
 	/*if !border && el.IsLink {
 		dui.Display.SwitchCursor(&draw.Cursor{
@@ -715,95 +992,118 @@ func (el *Element) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw
 }
 
 func (el *Element) mouseSelect(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) (consumed bool) {
+	b := el.b
+	if m.Buttons != 0 && el.m.Buttons == 0 {
+		b.editing = false
+		if b.focused != nil {
+			b.focused = nil
+			b.scroller.Redraw()
+			dui.MarkDraw(b.scroller)
+		}
+	}
+	if b.editing {
+		// fields and text areas select and snarf themselves
+		return false
+	}
 	mouseDrag := m != origM
 	changed := false
-	if mouseDrag {
-		from := origM.Point.Add(orig)
-		to := m.Point.Add(orig)
-		r := draw.Rectangle{
-			draw.Point{from.X, from.Y},
-			draw.Point{to.X, to.Y},
-		}
-		// make sure the same coordinates are used
-		// (TODO: should be consistent in the first place)
-		if rc := r.Canon(); r == rc {
-			r = r.Sub(r.Min.Sub(fromLabel.Rect().Min))
-		} else {
-			r = rc.Sub(rc.Max.Sub(fromLabel.Rect().Max))
-		}
-		if !rectsSimilar(dragRect, r) {
-			TraverseTree(el, func(ui duit.UI) {
-				l, ok := ui.(*duitx.Label)
-				if !ok {
-					return
+	if mouseDrag && m.Buttons&1 == 1 && b.fromLabel != nil {
+		var ls []*duitx.Label
+		i0 := -1
+		TraverseTree(el, func(ui duit.UI) {
+			if l, ok := ui.(*duitx.Label); ok && l != nil {
+				if l == b.fromLabel {
+					i0 = len(ls)
 				}
-				sel := l.Rect().Overlaps(r)
+				ls = append(ls, l)
+			}
+		})
+		if i0 >= 0 {
+			i1 := labelAt(ls, m.Point.Add(orig))
+			if i1 < i0 {
+				i0, i1 = i1, i0
+			}
+			for i, l := range ls {
+				sel := i0 <= i && i <= i1
 				if sel == l.Selected {
-					return
+					continue
 				}
 				l.Selected = sel
 				changed = true
 				if sel {
-					selected++
+					b.selected++
 				} else {
-					selected--
+					b.selected--
 				}
-			})
-			dragRect = r
+			}
 		}
 		if m.Buttons&2 == 2 && el.m.Buttons&2 == 0 {
-			var s string
-			var last *duitx.Label
-			TraverseTree(el, func(ui duit.UI) {
-				l, ok := ui.(*duitx.Label)
-				if ok && l.Selected {
-					if last != nil && l.Rect().Min.Y > last.Rect().Min.Y {
-						s += "\n"
-					}
-					s += l.Text
-					last = l
-					return
-				}
-			})
-			s = strings.TrimSpace(s)
-			s = strings.TrimFunc(s, func(r rune) bool {
-				return !unicode.IsGraphic(r)
-			})
-			s = strings.Map(func(r rune) rune {
-				if unicode.IsSpace(r) && r != '\n' {
-					return ' '
-				}
-				return r
-			}, s)
-			dui.WriteSnarf([]byte(s))
+			dui.WriteSnarf([]byte(selectedText(el)))
 		}
-	} else if selected > 0 && m.Buttons == 1 {
-		TraverseTree(el.b.Website.UI, func(ui duit.UI) {
+	} else if b.selected > 0 && m.Buttons == 1 {
+		TraverseTree(b.Website.UI, func(ui duit.UI) {
 			l, ok := ui.(*duitx.Label)
 			if ok && l.Selected {
-				selected--
+				b.selected--
 				changed = true
 				l.Selected = false
 			}
 		})
-		selected = 0
+		b.selected = 0
 	}
 	return changed
 }
 
-func rectsSimilar(r, rr draw.Rectangle) bool {
-	deltas := []float64{
-		math.Abs(float64(r.Min.X - rr.Min.X)),
-		math.Abs(float64(r.Min.Y - rr.Min.Y)),
-		math.Abs(float64(r.Max.X - rr.Max.X)),
-		math.Abs(float64(r.Max.Y - rr.Max.Y)),
-	}
-	for _, d := range deltas {
-		if d > float64(dui.Scale(10)) {
-			return false
+// labelAt returns the index of the last label in ls (in document
+// order) that starts before p in reading order.
+func labelAt(ls []*duitx.Label, p image.Point) (i int) {
+	for j, l := range ls {
+		r := l.Rect()
+		if r.Max.Y <= p.Y || (r.Min.Y <= p.Y && r.Min.X <= p.X) {
+			i = j
 		}
 	}
-	return true
+	return
+}
+
+// editable elements handle selections and snarf themselves
+func (el *Element) editable() bool {
+	ui := el.UI
+	if bx, ok := ui.(*duitx.Box); ok && len(bx.Kids) == 1 {
+		ui = bx.Kids[0].UI
+	}
+	switch ui.(type) {
+	case *duit.Field, *duit.Edit, *CodeView:
+		return true
+	}
+	return false
+}
+
+// selectedText of the labels below ui
+func selectedText(ui duit.UI) string {
+	var s string
+	var last *duitx.Label
+	TraverseTree(ui, func(ui duit.UI) {
+		l, ok := ui.(*duitx.Label)
+		if ok && l.Selected {
+			if last != nil && l.Rect().Min.Y > last.Rect().Min.Y {
+				s += "\n"
+			}
+			s += l.Text
+			last = l
+			return
+		}
+	})
+	s = strings.TrimSpace(s)
+	s = strings.TrimFunc(s, func(r rune) bool {
+		return !unicode.IsGraphic(r)
+	})
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) && r != '\n' {
+			return ' '
+		}
+		return r
+	}, s)
 }
 
 func (el *Element) FirstFocus(dui *duit.DUI, self *duit.Kid) *image.Point {
@@ -816,7 +1116,7 @@ func (el *Element) FirstFocus(dui *duit.DUI, self *duit.Kid) *image.Point {
 }
 
 func (el *Element) click() (consumed bool) {
-	if ExperimentalJsInsecure {
+	if el.b.jsEnabled() && el.b.js != nil {
 		q := el.n.QueryRef()
 		var res string
 		var err error
@@ -824,9 +1124,11 @@ func (el *Element) click() (consumed bool) {
 		if err != nil {
 			log.Errorf("trigger click %v: %v", q, err)
 		} else if consumed {
-			offset := scroller.Offset
+			offset := el.b.scrollOffset()
 			el.b.Website.layout(el.b, res, ClickRelayout)
-			scroller.Offset = offset
+			if el.b.scroller != nil {
+				el.b.scroller.Offset = offset
+			}
 			dui.MarkLayout(dui.Top.UI)
 			dui.MarkDraw(dui.Top.UI)
 			dui.Render()
@@ -838,12 +1140,51 @@ func (el *Element) click() (consumed bool) {
 		return e.Consumed
 	}
 
+	return el.activate() || consumed
+}
+
+// activate the form control of el like a mouse click: buttons are
+// clicked, checkboxes toggled and radio buttons selected
+func (el *Element) activate() (consumed bool) {
+	if d := el.n.Data(); d != "input" && d != "button" {
+		return
+	}
+	TraverseTree(el.UI, func(ui duit.UI) {
+		if consumed {
+			return
+		}
+		switch c := ui.(type) {
+		case *duit.Button:
+			if c.Click != nil && !c.Disabled {
+				consumed = c.Click().Consumed
+			}
+		case *duit.Checkbox:
+			if !c.Disabled {
+				c.Checked = !c.Checked
+				if c.Changed != nil {
+					c.Changed()
+				}
+				consumed = true
+			}
+		case *duit.Radiobutton:
+			if !c.Disabled {
+				c.Select(dui)
+				if c.Changed != nil {
+					c.Changed(c.Value)
+				}
+				consumed = true
+			}
+		}
+	})
+	if consumed && dui != nil {
+		dui.MarkDraw(el)
+	}
 	return
 }
 
 // makeLink of el and its children
 func (el *Element) makeLink(href string) {
-	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+	if href == "" || href == "#" || strings.HasPrefix(href, "javascript:") {
 		return
 	}
 
@@ -852,11 +1193,16 @@ func (el *Element) makeLink(href string) {
 		log.Errorf("makeLink from %v: %v", href, err)
 		return
 	}
+	if !el.b.linkable(u) {
+		log.Errorf("makeLink: %v not allowed from %v", u, el.b.URL())
+		return
+	}
 	f := el.b.SetAndLoadUrl(u)
 	TraverseTree(el, func(ui duit.UI) {
 		el, ok := ui.(*Element)
 		if ok && el != nil {
 			el.IsLink = true
+			el.link = u
 			el.Click = f
 			return
 		}
@@ -877,6 +1223,15 @@ func attr(n html.Node, key string) (val string) {
 	return
 }
 
+func removeAttr(n *html.Node, key string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+			return
+		}
+	}
+}
+
 func hasAttr(n html.Node, key string) bool {
 	for _, a := range n.Attr {
 		if a.Key == key {
@@ -1275,11 +1630,21 @@ func NodeToBox(r int, b *Browser, n *nodes.Node) (el *Element) {
 		case "style", "script", "template":
 			return
 		case "input":
-			t := n.Attr("type")
-			if t == "" || t == "text" || t == "email" || t == "search" || t == "password" {
+			switch n.Attr("type") {
+			case "", "text", "email", "search", "password", "number", "date", "url", "tel":
 				return NewInputField(b, n)
-			} else if t == "submit" {
+			case "submit":
 				return NewSubmitButton(b, n)
+			case "reset":
+				return NewResetButton(b, n)
+			case "checkbox":
+				return NewCheckbox(b, n)
+			case "radio":
+				return NewRadio(b, n)
+			case "file":
+				return NewFileInput(b, n)
+			case "hidden":
+				return
 			}
 		case "select":
 			return NewSelect(b, n)
@@ -1288,6 +1653,8 @@ func NodeToBox(r int, b *Browser, n *nodes.Node) (el *Element) {
 		case "button":
 			if t := n.Attr("type"); t == "" || t == "submit" {
 				return NewSubmitButton(b, n)
+			} else if t == "reset" {
+				return NewResetButton(b, n)
 			}
 
 			btn := &duit.Button{
@@ -1341,7 +1708,7 @@ func NodeToBox(r int, b *Browser, n *nodes.Node) (el *Element) {
 			el.makeLink(href)
 			return el
 		case "noscript":
-			if ExperimentalJsInsecure || !EnableNoScriptTag {
+			if b.jsEnabled() || !EnableNoScriptTag {
 				return
 			}
 			fallthrough
@@ -1444,6 +1811,7 @@ func traverseTree(r int, ui duit.UI, f func(ui duit.UI)) {
 	case *duit.Field:
 	case *duit.Edit:
 	case *duit.Button:
+	case *duit.Checkbox, *duit.Radiobutton:
 	case *duit.List:
 	case *duit.Place:
 		for _, kid := range v.Kids {
@@ -1518,104 +1886,570 @@ type Browser struct {
 	cancel context.CancelFunc
 
 	history.History
-	dui      *duit.DUI
-	js       *js.JS
-	fs       *fs.FS
-	Website  *Website
-	loading  bool
-	client   *http.Client
-	Download func(res chan *string)
-	LocCh    chan string
-	StatusCh chan string
+	sessionFile string
+	restore     []string
+	dui         *duit.DUI
+	js          *js.JS
+	fs          *fs.FS
+	Website     *Website
+	loading     bool
+	client      *http.Client
+	Download    func(u *url.URL, fn string, res chan *string)
+	PickFile    func(res chan *string)
+	OpenTab     func(u *url.URL)
+	FindText    func(q string) int // find from the ctl file through the UI
+	Menu        func(items []string, res chan int)
+	LocCh       chan string
+	StatusCh    chan string
+
+	scroller   *duitx.Scroll
+	imageCache map[string]*draw.Image
+
+	selected  int
+	fromLabel *duitx.Label
+	editing   bool // mouse pressed in a form field
+
+	focused   *Element
+	hints     map[*Element]string // link hints
+	hintInput string
+
+	found  [][]*duitx.Label // search matches
+	foundI int
+
+	radios map[radioKey]duit.RadiobuttonGroup
+
+	// files picked by the user for file inputs, never taken from
+	// the page itself
+	files map[*html.Node]string
+
+	reader  bool // show only the main content
+	partial bool // the page is shown while still loading
+
+	nBlocked atomic.Int64 // blocked requests of the current page
 }
 
-func NewBrowser(_dui *duit.DUI, initUrl string) (b *Browser) {
-	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
+func newClient(jar *cookies.Jar) *http.Client {
 	tr := http.DefaultTransport.(*http.Transport).Clone()
 	tr.MaxIdleConns = 10
 	tr.MaxConnsPerHost = 6
 	tr.MaxIdleConnsPerHost = 6
+	tr.RegisterProtocol("gemini", gemini.Transport{})
+	tr.RegisterProtocol("gopher", gopher.Transport{})
+	if Webfs && webfs.Available {
+		w := webfs.New()
+		tr.RegisterProtocol("http", w)
+		tr.RegisterProtocol("https", w)
+	}
+	return &http.Client{
+		Jar:           permJar{jar},
+		Transport:     tr,
+		CheckRedirect: checkRedirect,
+	}
+}
+
+// fileClient opens file: URLs for top-level navigation and for
+// resources of local pages
+var fileClient = &http.Client{
+	Transport:     file.Transport{},
+	CheckRedirect: checkRedirect,
+}
+
+// checkRedirect rejects redirects to other schemes except
+// between http and https
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	from := via[len(via)-1].URL.Scheme
+	to := req.URL.Scheme
+	if from != to && !(web(from) && web(to)) {
+		return fmt.Errorf("redirect from %v to %v", from, to)
+	}
+	return nil
+}
+
+func web(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
+
+// linkable reports whether the current page may navigate to or
+// load u. Only local pages may use file: URLs.
+func (b *Browser) linkable(u *url.URL) bool {
+	return u.Scheme != "file" || (b.History.Len() > 0 && b.URL().Scheme == "file")
+}
+
+func newBrowser(client *http.Client, f *fs.FS) (b *Browser) {
 	b = &Browser{
-		client: &http.Client{
-			Jar:       jar,
-			Transport: tr,
-		},
-		dui:      _dui,
-		LocCh:    make(chan string, 10),
-		StatusCh: make(chan string, 10),
+		client:     client,
+		dui:        dui,
+		fs:         f,
+		imageCache: make(map[string]*draw.Image),
+		LocCh:      make(chan string, 10),
+		StatusCh:   make(chan string, 10),
 	}
 	b.Website = &Website{b: b}
-	u, err := url.Parse(initUrl)
-	if err != nil {
-		log.Fatalf("parse: %v", err)
-	}
-	b.History.Push(u, 0)
 	b.Website.UI = &duit.Label{}
-	style.SetFetcher(b)
+	return
+}
+
+func NewBrowser(_dui *duit.DUI, initUrl string) (b *Browser) {
+	var err error
 	dui = _dui
-	dui.Background, err = dui.Display.AllocImage(image.Rect(0, 0, 10, 10), draw.ARGB32, true, 0x00000000)
+	b = newBrowser(nil, fs.New())
+	cookiesFile := ""
+	if d, err := mycel.CacheDir(); err == nil {
+		if err := cache.SetDir(d); err != nil {
+			log.Errorf("cache dir: %v", err)
+		}
+	} else {
+		log.Errorf("cache dir: %v", err)
+	}
+	if d, err := mycel.ConfigDir(); err == nil {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			log.Errorf("config dir: %v", err)
+		}
+		sessionBase = d + "/history"
+		if err := block.LoadDir(d + "/filters"); err != nil {
+			log.Errorf("filters: %v", err)
+		}
+		if err := perm.SetFile(d + "/permissions"); err != nil {
+			log.Errorf("permissions: %v", err)
+		}
+		if err := zoom.SetFile(d + "/zoom"); err != nil {
+			log.Errorf("zoom: %v", err)
+		}
+		if err := gemini.SetFile(d + "/gemini_hosts"); err != nil {
+			log.Errorf("gemini hosts: %v", err)
+		}
+		style.SetUserDir(d)
+		cookiesFile = d + "/cookies.txt"
+		if err := bookmarks.SetFile(d + "/bookmarks"); err != nil {
+			log.Errorf("bookmarks: %v", err)
+		}
+	} else {
+		log.Errorf("config dir: %v", err)
+	}
+	jar, err := cookies.New(cookiesFile)
 	if err != nil {
+		log.Errorf("cookies: %v", err)
+		jar, _ = cookies.New("")
+	}
+	b.client = newClient(jar)
+	b.fs.Cookies = jar
+	var u *url.URL
+	b.restore = sessionFiles()
+	if initUrl != "" {
+		for _, fn := range b.restore {
+			os.Remove(fn)
+		}
+		b.restore = nil
+	}
+	if h, fn, ok := b.nextSession(); ok {
+		b.History = h
+		b.sessionFile = fn
+		u = h.URL()
+	} else {
+		if initUrl == "" {
+			initUrl = DefaultUrl
+		}
+		if u, err = url.Parse(initUrl); err != nil {
+			log.Fatalf("parse: %v", err)
+		}
+		b.History.Push(u, 0)
+		b.sessionFile = newSessionFile()
+	}
+	b.LocCh <- u.String()
+	style.SetFetcher(b)
+	if err := initDisplay(); err != nil {
 		log.Fatalf("%v", err)
 	}
-	display = dui.Display
+
+	b.fs.Fetcher = b
+	go b.fs.Srv9p()
 	b.LoadUrl(u)
 
-	if ExperimentalJsInsecure {
-		b.fs.Client = &http.Client{}
-		b.fs.Fetcher = b
+	return
+}
+
+// initDisplay with a transparent background for the page
+func initDisplay() (err error) {
+	dui.Background, err = dui.Display.AllocImage(image.Rect(0, 0, 10, 10), draw.ARGB32, true, 0x00000000)
+	if err != nil {
+		return fmt.Errorf("alloc background: %w", err)
 	}
-	go b.fs.Srv9p()
+	display = dui.Display
+	return
+}
 
+// NewTab loading u in a new Browser that shares cookies and the
+// 9P filesystem with b. The new tab has its own history, scroll
+// position and JS instance. The callbacks need to be set by the
+// caller before reading from LocCh and StatusCh.
+func (b *Browser) NewTab(u *url.URL) (t *Browser) {
+	t = newBrowser(b.client, b.fs)
+	t.History.Push(u, 0)
+	t.sessionFile = newSessionFile()
+	t.saveHistory()
+	t.LocCh <- u.String()
+	t.LoadUrl(u)
 	return
 }
 
-func (b *Browser) LinkedUrl(addr string) (a *url.URL, err error) {
-	log.Printf("LinkedUrl: addr=%v, b.URL=%v", addr, b.URL())
-	if strings.HasPrefix(addr, "//") {
-		addr = b.URL().Scheme + ":" + addr
-	} else if strings.HasPrefix(addr, "/") {
-		addr = b.URL().Scheme + "://" + b.URL().Host + addr
-	} else if !strings.HasPrefix(addr, "http") {
-		if strings.HasSuffix(b.URL().Path, "/") {
-			addr = "/" + b.URL().Path + addr
-		} else {
-			m := strings.LastIndex(b.URL().Path, "/")
-			if m > 0 {
-				folder := b.URL().Path[0:m]
-				addr = "/" + folder + "/" + addr
-			} else {
-				addr = "/" + addr
-			}
+// RestoreTabs opens the other tabs of the last session when b
+// restored it.
+func (b *Browser) RestoreTabs() (ts []*Browser) {
+	for {
+		h, fn, ok := b.nextSession()
+		if !ok {
+			return
 		}
-		addr = strings.ReplaceAll(addr, "//", "/")
-		addr = b.URL().Scheme + "://" + b.URL().Host + addr
+		t := newBrowser(b.client, b.fs)
+		t.History = h
+		t.sessionFile = fn
+		t.LocCh <- h.URL().String()
+		t.LoadUrl(h.URL())
+		ts = append(ts, t)
 	}
-	return url.Parse(addr)
 }
 
-func (b *Browser) Origin() *url.URL {
-	return b.History.URL()
+// Activate makes the 9P filesystem show this tab
+func (b *Browser) Activate() {
+	b.fs.Fetcher = b
+	style.SetFetcher(b)
+	b.Website.updateFS()
 }
 
-func (b *Browser) Back() (e duit.Event) {
-	if !b.loading {
-		b.History.Back()
-		b.LocCh <- b.History.URL().String()
-		b.LoadUrl(b.History.URL())
+// Close the tab
+func (b *Browser) Close() {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.js.Stop()
+	b.js = nil
+	if b.scroller != nil {
+		b.scroller.Free()
+		b.scroller = nil
+	}
+	if b.sessionFile != "" {
+		os.Remove(b.sessionFile)
+		b.sessionFile = ""
 	}
-	e.Consumed = true
-	return
 }
 
-func (b *Browser) SetAndLoadUrl(u *url.URL) func() duit.Event {
-	return func() duit.Event {
+// ParseLocation typed by the user, absolute paths are local files
+// and addresses without scheme use http
+func ParseLocation(a string) (*url.URL, error) {
+	if strings.HasPrefix(a, "/") {
+		a = "file://" + a
+	} else if l := strings.ToLower(a); !strings.HasPrefix(l, "http") && !strings.HasPrefix(l, "about:") &&
+		!strings.HasPrefix(l, "file:") && !strings.HasPrefix(l, "gemini:") && !strings.HasPrefix(l, "gopher:") {
+		a = "http://" + a
+	}
+	return url.Parse(a)
+}
+
+func (b *Browser) LinkedUrl(addr string) (a *url.URL, err error) {
+	log.Printf("LinkedUrl: addr=%v, b.URL=%v", addr, b.URL())
+	ref, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+	return b.URL().ResolveReference(ref), nil
+}
+
+// Title of the current page
+func (b *Browser) Title() string {
+	return b.Website.title
+}
+
+func (b *Browser) Origin() *url.URL {
+	return b.History.URL()
+}
+
+// perm returns the permissions of the current site
+func (b *Browser) perm() perm.Perm {
+	if b == nil || b.History.Len() == 0 {
+		return perm.Default
+	}
+	return perm.Get(b.Origin())
+}
+
+func (b *Browser) jsEnabled() bool {
+	return b.perm().JS
+}
+
+// Zoom in, out if delta is negative or reset it if delta is 0.
+// The zoom level is remembered for the site and the page is
+// layouted again keeping the scroll position.
+func (b *Browser) Zoom(delta int) {
+	if b.History.Len() == 0 {
+		return
+	}
+	old := zoom.Get(b.Origin())
+	z := zoom.Step(old, delta)
+	if err := zoom.Set(b.Origin(), z); err != nil {
+		log.Errorf("set zoom: %v", err)
+	}
+	offset := b.scrollOffset()
+	go func() {
+		b.StatusCh <- fmt.Sprintf("Zoom %v%%", math.Round(z*100))
+		b.Website.relayout()
+		dui.Call <- func() {
+			if b.scroller != nil {
+				b.scroller.Offset = int(float64(offset) * z / old)
+			}
+			dui.MarkLayout(dui.Top.UI)
+			dui.MarkDraw(dui.Top.UI)
+			dui.Render()
+		}
+	}()
+}
+
+// permJar only stores and sends cookies of sites that allow them
+type permJar struct {
+	http.CookieJar
+}
+
+func (j permJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if perm.Get(u).Cookies {
+		j.CookieJar.SetCookies(u, cookies)
+	}
+}
+
+func (j permJar) Cookies(u *url.URL) []*http.Cookie {
+	if !perm.Get(u).Cookies {
+		return nil
+	}
+	return j.CookieJar.Cookies(u)
+}
+
+// typedGetter is implemented by Fetchers that can be told the
+// resource type
+type typedGetter interface {
+	GetAs(*url.URL, block.Type) ([]byte, mycel.ContentType, error)
+}
+
+// getAs gets u with f as resource type t if supported
+func getAs(f mycel.Fetcher, u *url.URL, t block.Type) ([]byte, mycel.ContentType, error) {
+	if g, ok := f.(typedGetter); ok {
+		return g.GetAs(u, t)
+	}
+	return f.Get(u)
+}
+
+// Block checks whether the request to u should be blocked
+// and counts it in that case.
+func (b *Browser) Block(u *url.URL, t block.Type) bool {
+	var origin *url.URL
+	if t != block.Document && b.History.Len() > 0 {
+		origin = b.Origin()
+	}
+	if !block.Blocked(u, t, origin) {
+		return false
+	}
+	log.Printf("blocked %v", u)
+	b.nBlocked.Add(1)
+	return true
+}
+
+func (b *Browser) Back() (e duit.Event) {
+	if !b.loading && b.History.CanBack() {
+		prev := b.History.URL()
+		b.History.Back(b.scrollOffset())
+		b.loadHistoryUrl(prev)
+	}
+	e.Consumed = true
+	return
+}
+
+func (b *Browser) Forward() (e duit.Event) {
+	if !b.loading && b.History.CanForward() {
+		prev := b.History.URL()
+		b.History.Forward(b.scrollOffset())
+		b.loadHistoryUrl(prev)
+	}
+	e.Consumed = true
+	return
+}
+
+// GoHistory loads the i-th history item
+func (b *Browser) GoHistory(i int) (e duit.Event) {
+	if !b.loading {
+		prev := b.History.URL()
+		b.History.Go(i, b.scrollOffset())
+		b.loadHistoryUrl(prev)
+	}
+	e.Consumed = true
+	return
+}
+
+// loadHistoryUrl after moving in the history away from prev.
+// Within the same document only the scroll position is restored.
+func (b *Browser) loadHistoryUrl(prev *url.URL) {
+	b.saveHistory()
+	b.LocCh <- b.History.URL().String()
+	if b.scroller != nil && sameDocument(prev, b.History.URL()) {
+		b.scroller.Offset = b.History.Scroll()
+		if b.scroller.Offset == 0 {
+			b.scrollToFragment(b.History.URL().Fragment)
+		}
+		dui.MarkDraw(b.scroller)
+		dui.Render()
+		return
+	}
+	b.LoadUrl(b.History.URL())
+}
+
+func (b *Browser) scrollOffset() int {
+	if b.scroller == nil {
+		return 0
+	}
+	return b.scroller.Offset
+}
+
+// scrollToFragment scrolls to the element with the id or name frag
+func (b *Browser) scrollToFragment(frag string) (ok bool) {
+	if b.scroller == nil || b.Website.nt == nil {
+		return
+	}
+	a := anchor(b.Website.nt, frag)
+	if a == nil {
+		log.Printf("anchor %v not found", frag)
+		return
+	}
+	// empty anchors have no element, use the next one then
+	seen := false
+	y := 0
+	b.Website.nt.Traverse(func(_ int, n *nodes.Node) {
+		if n == a {
+			seen = true
+		}
+		if seen && !ok && n.Rectangular != nil && !n.Rect().Empty() {
+			y = n.Rect().Min.Y
+			ok = true
+		}
+	})
+	if ok {
+		b.scroller.SetOffset(y)
+	}
+	return
+}
+
+// anchor with id frag or an a element with name frag
+func anchor(nt *nodes.Node, frag string) (a *nodes.Node) {
+	nt.Traverse(func(_ int, n *nodes.Node) {
+		if a != nil || n.Type() != html.ElementNode {
+			return
+		}
+		if n.Attr("id") == frag || (n.Data() == "a" && n.Attr("name") == frag) {
+			a = n
+		}
+	})
+	return
+}
+
+// sameDocument is true if the urls only differ in the fragment
+func sameDocument(a, b *url.URL) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	aa, bb := *a, *b
+	aa.Fragment, aa.RawFragment = "", ""
+	bb.Fragment, bb.RawFragment = "", ""
+	return aa.String() == bb.String()
+}
+
+// saveHistory to the session file so that it can be restored
+func (b *Browser) saveHistory() {
+	if b.sessionFile == "" {
+		return
+	}
+	f, err := os.Create(b.sessionFile)
+	if err != nil {
+		log.Errorf("save history: %v", err)
+		return
+	}
+	defer f.Close()
+	if err := b.History.Save(f); err != nil {
+		log.Errorf("save history: %v", err)
+	}
+}
+
+// sessionBase is the session file of the first tab, the other tabs
+// use sessionBase.1, sessionBase.2, ...
+var sessionBase string
+
+// sessionFiles of the last session in tab order
+func sessionFiles() (fns []string) {
+	if sessionBase == "" {
+		return
+	}
+	if _, err := os.Stat(sessionBase); err == nil {
+		fns = append(fns, sessionBase)
+	}
+	more, _ := filepath.Glob(sessionBase + ".*")
+	num := func(fn string) int {
+		n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Ext(fn), "."))
+		return n
+	}
+	sort.Slice(more, func(i, j int) bool { return num(more[i]) < num(more[j]) })
+	return append(fns, more...)
+}
+
+// newSessionFile that is not used by another tab
+func newSessionFile() string {
+	if sessionBase == "" {
+		return ""
+	}
+	fn := sessionBase
+	for i := 1; ; i++ {
+		if _, err := os.Stat(fn); os.IsNotExist(err) {
+			return fn
+		}
+		fn = fmt.Sprintf("%s.%d", sessionBase, i)
+	}
+}
+
+// nextSession to be restored, unreadable files are dropped
+func (b *Browser) nextSession() (h history.History, fn string, ok bool) {
+	for len(b.restore) > 0 {
+		fn, b.restore = b.restore[0], b.restore[1:]
+		h, err := loadHistory(fn)
+		if err == nil {
+			return h, fn, true
+		}
+		log.Errorf("restore %v: %v", fn, err)
+		os.Remove(fn)
+	}
+	return
+}
+
+func loadHistory(fn string) (h history.History, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return history.Load(f)
+}
+
+func (b *Browser) SetAndLoadUrl(u *url.URL) func() duit.Event {
+	return func() duit.Event {
+		if u.Fragment != "" && !b.loading && b.scroller != nil && sameDocument(u, b.URL()) {
+			b.History.Push(u, b.scrollOffset())
+			b.saveHistory()
+			b.LocCh <- u.String()
+			b.scrollToFragment(u.Fragment)
+			dui.MarkDraw(b.scroller)
+			return duit.Event{
+				Consumed: true,
+			}
+		}
+
 		// Stop updating existing widgets
-		if scroller != nil {
-			scroller.Free()
-			scroller = nil
+		if b.scroller != nil {
+			b.scroller.Free()
+			b.scroller = nil
 		}
 		b.showBodyMessage("")
 
@@ -1666,9 +2500,33 @@ func (b *Browser) LoadUrl(url *url.URL) (e duit.Event) {
 }
 
 func (b *Browser) loadUrl(url *url.URL) {
+	b.nBlocked.Store(0)
 	b.StatusCh <- fmt.Sprintf("Load %v...", url)
-	buf, contentType, err := b.get(url, true)
+	// downloads continue when the next page is loaded
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(b.ctx, cancel)
+	resp, contentType, err := b.open(ctx, url, true)
+	if err == nil && (contentType.IsHTML() || contentType.IsPlain() || contentType.IsEmpty()) {
+		var buf []byte
+		b.newPage()
+		buf, err = b.stream(contentType, resp)
+		stop()
+		cancel()
+		if err == nil {
+			b.show(contentType, buf, InitialLayout)
+			return
+		} else if b.partial {
+			log.Errorf("error loading %v: %v", url, err)
+			b.status("Stopped")
+			dui.Call <- func() {
+				b.loading = false
+			}
+			return
+		}
+	}
 	if err != nil {
+		stop()
+		cancel()
 		log.Errorf("error loading %v: %v", url, err)
 		if er := errors.Unwrap(err); er != nil {
 			err = er
@@ -1677,38 +2535,91 @@ func (b *Browser) loadUrl(url *url.URL) {
 		b.loading = false
 		return
 	}
-	if contentType.IsHTML() || contentType.IsPlain() || contentType.IsEmpty() {
-		b.render(contentType, buf)
-	} else {
-		res := make(chan *string, 1)
-		b.Download(res)
-
-		log.Infof("Download unhandled content type: %v", contentType)
-
-		fn := <-res
+	log.Infof("Download unhandled content type: %v", contentType)
+	stop()
+	b.download(resp, cancel)
+	dui.Call <- func() {
+		b.loading = false
+	}
+}
 
-		if fn != nil && *fn != "" {
-			log.Infof("Download to %v", *fn)
-			f, _ := os.Create(*fn)
-			f.Write(buf)
-			f.Close()
-		}
-		dui.Call <- func() {
-			b.loading = false
+// download resp to a file chosen by the user and show the progress
+// in the status bar. cancel releases the request.
+func (b *Browser) download(resp *http.Response, cancel context.CancelFunc) {
+	u := resp.Request.URL
+	res := make(chan *string, 1)
+	b.Download(u, downloads.Unique(filepath.Join(downloads.Dir(), downloads.Name(resp.Header, u))), res)
+	fn := <-res
+	if fn == nil || *fn == "" {
+		resp.Body.Close()
+		cancel()
+		return
+	}
+	log.Infof("Download to %v", *fn)
+	d, err := downloads.Start(u, resp.Body, resp.ContentLength, *fn)
+	if err != nil {
+		log.Errorf("download: %v", err)
+		b.status(fmt.Sprintf("Download failed: %v", err))
+		cancel()
+		return
+	}
+	go func() {
+		t := time.NewTicker(500 * time.Millisecond)
+		defer t.Stop()
+		defer cancel()
+		for {
+			select {
+			case <-t.C:
+				b.status(fmt.Sprintf("Download %v: %v", filepath.Base(d.File), d.Status()))
+			case <-d.Done():
+				b.status(fmt.Sprintf("Download %v: %v", filepath.Base(d.File), d.Status()))
+				return
+			}
 		}
+	}()
+}
+
+// status message unless the status bar is busy or the tab closed
+func (b *Browser) status(msg string) {
+	select {
+	case b.StatusCh <- msg:
+	default:
 	}
 }
 
 func (b *Browser) render(ct mycel.ContentType, buf []byte) {
+	b.newPage()
+	b.show(ct, buf, InitialLayout)
+}
+
+// newPage empties the caches of the previous page
+func (b *Browser) newPage() {
 	log.Printf("Empty some cache...")
 	cache.Tidy()
-	imageCache = make(map[string]*draw.Image)
+	b.imageCache = make(map[string]*draw.Image)
+	b.found = nil
+	b.files = nil
+	b.partial = false
+	b.Website.sheets = make(map[string]string)
+}
 
+// show buf laid out. Partial layouts of pages still loading keep
+// the scroll position.
+func (b *Browser) show(ct mycel.ContentType, buf []byte, layouting int) {
+	offset := b.History.Scroll()
+	if b.partial && offset == 0 {
+		offset = b.scrollOffset()
+	}
 	b.Website.ContentType = ct
 	htm := ct.Utf8(buf)
-	b.Website.layout(b, htm, InitialLayout)
+	b.Website.layout(b, htm, layouting)
+	if n := b.nBlocked.Load(); n > 0 && layouting != PartialLayout {
+		b.StatusCh <- fmt.Sprintf("%v requests blocked", n)
+	}
 
 	log.Printf("Render...")
+	wasPartial := b.partial
+	b.partial = layouting == PartialLayout
 	dui.Call <- func() {
 		TraverseTree(b.Website.UI, func(ui duit.UI) {
 			// just checking for nil elements. That would be a bug anyway and it's better
@@ -1719,76 +2630,342 @@ func (b *Browser) render(ct mycel.ContentType, buf []byte) {
 			}
 		})
 		PrintTree(b.Website.UI)
-		if scroller != nil {
-			scroller.Offset = b.History.Scroll()
+		if b.scroller != nil {
+			b.scroller.Offset = offset
 		}
 		dui.MarkLayout(dui.Top.UI)
 		dui.MarkDraw(dui.Top.UI)
 		dui.Render()
+		if layouting == PartialLayout {
+			return
+		}
+		if f := b.URL().Fragment; f != "" && offset == 0 && !wasPartial && b.scrollToFragment(f) {
+			// positions are only known after the first draw
+			dui.MarkDraw(dui.Top.UI)
+			dui.Render()
+		}
 		b.loading = false
 	}
 	log.Printf("Rendering done")
 }
 
-func (b *Browser) Get(uri *url.URL) (buf []byte, contentType mycel.ContentType, err error) {
-	c, ok := cache.Get(uri.String())
-	if ok {
-		log.Printf("use %v from cache", uri)
-	} else {
-		c.Addr = uri.String()
-		c.Buf, c.ContentType, err = b.get(uri, false)
-		if err == nil {
-			cache.Set(c)
+// stream the body of resp. The HTML that arrived so far is shown
+// once the head is complete and again whenever it has doubled.
+// Partial layouts run besides reading so they don't stall the
+// download, only the latest snapshot is laid out.
+func (b *Browser) stream(ct mycel.ContentType, resp *http.Response) (buf []byte, err error) {
+	defer resp.Body.Close()
+	var (
+		bb    bytes.Buffer
+		head  bool
+		shown int
+	)
+	snaps := make(chan []byte, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for snap := range snaps {
+			if resp.Request.Context().Err() == nil {
+				b.show(ct, snap, PartialLayout)
+			}
+		}
+	}()
+	defer func() {
+		close(snaps)
+		<-done
+	}()
+	p := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(p)
+		bb.Write(p[:n])
+		if err == io.EOF {
+			return bb.Bytes(), nil
+		} else if err != nil {
+			return nil, err
+		}
+		msg := fmt.Sprintf("Load %v... %v KB", resp.Request.URL, bb.Len()/1024)
+		if resp.ContentLength > 0 {
+			msg += fmt.Sprintf(" of %v KB", resp.ContentLength/1024)
+		}
+		b.status(msg)
+		if !ct.IsHTML() || bb.Len() < firstScreenful || bb.Len() < 2*shown {
+			continue
+		}
+		if !head {
+			l := bytes.ToLower(bb.Bytes())
+			head = bytes.Contains(l, []byte("</head")) || bytes.Contains(l, []byte("<body"))
+		}
+		if head {
+			// replace the snapshot not laid out yet
+			select {
+			case <-snaps:
+			default:
+			}
+			snaps <- bytes.Clone(bb.Bytes())
+			shown = bb.Len()
 		}
 	}
+}
 
-	return c.Buf, c.ContentType, err
+// Get uri, the resource type is guessed from the file extension
+func (b *Browser) Get(uri *url.URL) (buf []byte, contentType mycel.ContentType, err error) {
+	return b.GetAs(uri, block.TypeOf(uri))
 }
 
-func (b *Browser) get(uri *url.URL, isNewOrigin bool) (buf []byte, contentType mycel.ContentType, err error) {
-	log.Infof("Get %v", uri.String())
+// GetAs gets uri which is requested as resource type t
+func (b *Browser) GetAs(uri *url.URL, t block.Type) (buf []byte, contentType mycel.ContentType, err error) {
+	if b.Block(uri, t) {
+		return nil, mycel.ContentType{}, fmt.Errorf("blocked %v", uri)
+	}
+	if t == block.Image && !b.perm().Images {
+		return nil, mycel.ContentType{}, fmt.Errorf("images disabled for %v", b.Origin())
+	}
+	if !b.linkable(uri) {
+		return nil, mycel.ContentType{}, fmt.Errorf("%v not allowed from %v", uri, b.URL())
+	}
 	req, err := http.NewRequestWithContext(b.ctx, "GET", uri.String(), nil)
 	if err != nil {
 		return
 	}
 	req.Header.Add("User-Agent", UserAgent)
-	resp, err := b.client.Do(req)
+	c, ok := cache.Get(req)
+	if ok && c.Fresh(time.Now()) {
+		log.Printf("use %v from cache", uri)
+		return c.Buf, c.ContentType, nil
+	} else if ok {
+		c.SetValidators(req.Header)
+	}
+
+	log.Infof("Get %v", uri.String())
+	client := b.client
+	if uri.Scheme == "file" {
+		client = fileClient
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, mycel.ContentType{}, fmt.Errorf("error loading %v: %w", uri, err)
 	}
 	defer resp.Body.Close()
+	if ok && resp.StatusCode == http.StatusNotModified {
+		log.Printf("use %v from cache (not modified)", uri)
+		c.Revalidated(resp.Header)
+		cache.Set(c)
+		return c.Buf, c.ContentType, nil
+	}
+	buf, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, mycel.ContentType{}, fmt.Errorf("error reading")
+	}
+	contentType, err = mycel.NewContentType(resp.Header.Get("Content-Type"), resp.Request.URL)
+	if err != nil {
+		return
+	}
+	if it, ok := cache.NewItem(req, resp, buf, contentType); ok {
+		cache.Set(it)
+	}
+
+	return
+}
+
+func (b *Browser) get(uri *url.URL, isNewOrigin bool) (buf []byte, contentType mycel.ContentType, err error) {
+	resp, contentType, err := b.open(b.ctx, uri, isNewOrigin)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
 	buf, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, mycel.ContentType{}, fmt.Errorf("error reading")
 	}
+	return
+}
+
+// open uri and leave reading the response body to the caller
+func (b *Browser) open(ctx context.Context, uri *url.URL, isNewOrigin bool) (resp *http.Response, contentType mycel.ContentType, err error) {
+	log.Infof("Get %v", uri.String())
+	if page, ok := b.aboutPage(uri); ok {
+		if isNewOrigin {
+			b.push(uri)
+		}
+		contentType, err = mycel.NewContentType("text/html; charset=utf-8", uri)
+		resp = &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(page)),
+			Request:    &http.Request{URL: uri},
+		}
+		return
+	}
+	if b.Block(uri, block.Document) {
+		return nil, mycel.ContentType{}, fmt.Errorf("blocked %v", uri)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", uri.String(), nil)
+	if err != nil {
+		return
+	}
+	req.Header.Add("User-Agent", UserAgent)
+	c, cached := cache.Get(req)
+	if cached && c.Fresh(time.Now()) {
+		log.Printf("use %v from cache", uri)
+		resp = cachedResponse(req, c)
+	} else {
+		if cached {
+			c.SetValidators(req.Header)
+		}
+		client := b.client
+		if uri.Scheme == "file" {
+			client = fileClient
+		}
+		if resp, err = client.Do(req); err != nil {
+			return nil, mycel.ContentType{}, fmt.Errorf("error loading %v: %w", uri, err)
+		}
+		if cached && resp.StatusCode == http.StatusNotModified {
+			log.Printf("use %v from cache (not modified)", uri)
+			resp.Body.Close()
+			c.Revalidated(resp.Header)
+			cache.Set(c)
+			resp = cachedResponse(req, c)
+		} else {
+			cached = false
+		}
+	}
 	contentType, err = mycel.NewContentType(resp.Header.Get("Content-Type"), resp.Request.URL)
+	if err != nil {
+		resp.Body.Close()
+		return
+	}
+	if _, ok := cache.NewItem(resp.Request, resp, nil, contentType); ok && !cached && (contentType.IsHTML() || contentType.IsPlain()) {
+		resp.Body = &cacheBody{ReadCloser: resp.Body, resp: resp, ct: contentType}
+	}
 	if isNewOrigin {
-		of := 0
-		if scroller != nil {
-			of = scroller.Offset
+		b.push(resp.Request.URL)
+	}
+	return
+}
+
+// cachedResponse to req with the body from the cache
+func cachedResponse(req *http.Request, c cache.Item) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        c.Header,
+		Body:          io.NopCloser(bytes.NewReader(c.Buf)),
+		ContentLength: int64(len(c.Buf)),
+		Request:       req,
+	}
+}
+
+// cacheBody stores the document in the cache once it is read
+// completely
+type cacheBody struct {
+	io.ReadCloser
+	resp *http.Response
+	ct   mycel.ContentType
+	buf  bytes.Buffer
+	done bool
+}
+
+func (cb *cacheBody) Read(p []byte) (n int, err error) {
+	n, err = cb.ReadCloser.Read(p)
+	cb.buf.Write(p[:n])
+	if err == io.EOF && !cb.done {
+		cb.done = true
+		if it, ok := cache.NewItem(cb.resp.Request, cb.resp, cb.buf.Bytes(), cb.ct); ok {
+			cache.Set(it)
+		}
+	}
+	return
+}
+
+// aboutPage generated for the bookmarks and downloads URLs
+func (b *Browser) aboutPage(uri *url.URL) (page []byte, ok bool) {
+	switch {
+	case uri.String() == bookmarks.URL:
+		return bookmarks.HTML(), true
+	case uri.Scheme+":"+uri.Opaque == downloads.URL:
+		if id := uri.Query().Get("cancel"); id != "" {
+			i, err := strconv.Atoi(id)
+			if err == nil {
+				err = downloads.Cancel(i)
+			}
+			if err != nil {
+				log.Errorf("cancel download %v: %v", id, err)
+			}
 		}
-		b.History.Push(resp.Request.URL, of)
-		log.Printf("b.History is now %s", b.History.String())
-		b.LocCh <- b.URL().String()
+		return downloads.HTML(), true
 	}
 	return
 }
 
+// push u to the history of visited pages
+func (b *Browser) push(u *url.URL) {
+	b.History.Push(u, b.scrollOffset())
+	b.saveHistory()
+	log.Printf("b.History is now %s", b.History.String())
+	b.LocCh <- b.URL().String()
+}
+
 func (b *Browser) PostForm(uri *url.URL, data url.Values) (buf []byte, contentType mycel.ContentType, err error) {
-	b.StatusCh <- "Posting..."
 	fb := strings.NewReader(escapeValues(b.Website.ContentType, data).Encode())
-	req, err := http.NewRequestWithContext(b.ctx, "POST", uri.String(), fb)
+	return b.post(uri, fmt.Sprintf("application/x-www-form-urlencoded; charset=%v", b.Website.Charset()), fb)
+}
+
+// PostMultipart sends data and the files at the paths in files
+// as multipart/form-data.
+func (b *Browser) PostMultipart(uri *url.URL, data, files url.Values) (buf []byte, contentType mycel.ContentType, err error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, vs := range escapeValues(b.Website.ContentType, data) {
+		for _, v := range vs {
+			if err = w.WriteField(k, v); err != nil {
+				return nil, mycel.ContentType{}, fmt.Errorf("write field: %w", err)
+			}
+		}
+	}
+	for k, fns := range files {
+		for _, fn := range fns {
+			if err = writeFile(w, k, fn); err != nil {
+				return nil, mycel.ContentType{}, fmt.Errorf("write file %v: %w", fn, err)
+			}
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, mycel.ContentType{}, fmt.Errorf("close: %w", err)
+	}
+	return b.post(uri, w.FormDataContentType(), &body)
+}
+
+// writeFile part, fn is empty if no file was chosen
+func writeFile(w *multipart.Writer, field, fn string) (err error) {
+	var f *os.File
+	if fn != "" {
+		if f, err = os.Open(fn); err != nil {
+			return
+		}
+		defer f.Close()
+		fn = filepath.Base(fn)
+	}
+	part, err := w.CreateFormFile(field, fn)
+	if err != nil || f == nil {
+		return
+	}
+	_, err = io.Copy(part, f)
+	return
+}
+
+func (b *Browser) post(uri *url.URL, ct string, body io.Reader) (buf []byte, contentType mycel.ContentType, err error) {
+	b.StatusCh <- "Posting..."
+	req, err := http.NewRequestWithContext(b.ctx, "POST", uri.String(), body)
 	if err != nil {
 		return
 	}
 	req.Header.Add("User-Agent", UserAgent)
-	req.Header.Set("Content-Type", fmt.Sprintf("application/x-www-form-urlencoded; charset=%v", b.Website.Charset()))
+	req.Header.Set("Content-Type", ct)
 	resp, err := b.client.Do(req)
 	if err != nil {
 		return nil, mycel.ContentType{}, fmt.Errorf("error loading %v: %w", uri, err)
 	}
 	defer resp.Body.Close()
-	b.History.Push(resp.Request.URL, scroller.Offset)
+	b.History.Push(resp.Request.URL, b.scrollOffset())
+	b.saveHistory()
 	buf, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, mycel.ContentType{}, fmt.Errorf("error reading")
@@ -2,8 +2,10 @@ package browser
 
 import (
 	"9fans.net/go/draw"
+	"context"
 	"fmt"
 	"github.com/mjl-/duit"
+	"github.com/psilva261/mycel"
 	"github.com/psilva261/mycel/browser/duitx"
 	"github.com/psilva261/mycel/logger"
 	"github.com/psilva261/mycel/nodes"
@@ -11,9 +13,16 @@ import (
 	"golang.org/x/net/html"
 	"image"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var (
@@ -26,6 +35,55 @@ func init() {
 	style.Init(nil)
 }
 
+var (
+	testDUIOnce sync.Once
+	testDUIErr  error
+	testDUIs    *duit.DUI
+)
+
+// testDUI sets dui to a display, it is skipped without devdraw. The
+// display is shared by the tests because fonts are cached across
+// pages. Calls from other goroutines are executed in the background.
+func testDUI(t *testing.T, ui duit.UI) {
+	if runtime.GOOS == "plan9" {
+		t.Skip("needs a window on plan9")
+	}
+	if _, err := exec.LookPath("devdraw"); err != nil {
+		t.Skip("needs devdraw")
+	}
+	testDUIOnce.Do(func() {
+		d, err := duit.NewDUI("test", &duit.DUIOpts{Dimensions: "800x600"})
+		if err != nil {
+			testDUIErr = err
+			return
+		}
+		testDUIs = d
+		go func() {
+			for {
+				select {
+				case e := <-d.Inputs:
+					d.Input(e)
+				case err := <-d.Error:
+					log.Errorf("duit: %v", err)
+				}
+			}
+		}()
+	})
+	if testDUIErr != nil {
+		t.Fatalf("test dui: %v", testDUIErr)
+	}
+	old := dui
+	dui = testDUIs
+	style.Init(dui)
+	dui.Call <- func() {
+		dui.Top.UI = ui
+	}
+	t.Cleanup(func() {
+		dui = old
+		style.Init(old)
+	})
+}
+
 type item struct {
 	orig   string
 	href   string
@@ -45,6 +103,32 @@ func TestElementClick(t *testing.T) {
 	}
 }
 
+func TestElementActivate(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<input type="checkbox">`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	nt := nodes.NewNodeTree(doc, style.Map{}, make(map[*html.Node]style.Map), nil)
+	ns, err := nt.Query("input")
+	if err != nil || len(ns) != 1 {
+		t.Fatalf("%v %v", ns, err)
+	}
+	changed := 0
+	cb := &duit.Checkbox{
+		Changed: func() (e duit.Event) {
+			changed++
+			return
+		},
+	}
+	el := &Element{UI: cb, n: ns[0]}
+	if !el.click() || !cb.Checked || changed != 1 {
+		t.Fatalf("%v %v", cb.Checked, changed)
+	}
+	if !el.click() || cb.Checked || changed != 2 {
+		t.Fatalf("%v %v", cb.Checked, changed)
+	}
+}
+
 func TestArrange(t *testing.T) {
 	b := &Browser{}
 	htm := `
@@ -132,6 +216,36 @@ func TestLinkedUrl(t *testing.T) {
 			href:   "/path/info",
 			expect: "https://example.com/path/info",
 		},
+		item{
+			orig:   "https://example.com/docs/page.html",
+			href:   "#frag",
+			expect: "https://example.com/docs/page.html#frag",
+		},
+		item{
+			orig:   "https://example.com/docs/page.html?q=1",
+			href:   "#frag",
+			expect: "https://example.com/docs/page.html?q=1#frag",
+		},
+		item{
+			orig:   "https://example.com/docs/page.html",
+			href:   "//cdn.example.com/a.css",
+			expect: "https://cdn.example.com/a.css",
+		},
+		item{
+			orig:   "file:///usr/share/doc/",
+			href:   "../man/index.html",
+			expect: "file:///usr/share/man/index.html",
+		},
+		item{
+			orig:   "file:///usr/share/doc/index.html",
+			href:   "file:///tmp/a.html",
+			expect: "file:///tmp/a.html",
+		},
+		item{
+			orig:   "gopher://example.com/1/docs",
+			href:   "gemini://example.com/",
+			expect: "gemini://example.com/",
+		},
 	}
 
 	for _, i := range items {
@@ -528,3 +642,199 @@ func TestWidths(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestAnchor(t *testing.T) {
+	htm := `<body><h1 id="top">Title</h1><p><a name="sec">Section</a></p><div id="end"></div></body>`
+	nt := dumpTree(t, htm)
+	for frag, exp := range map[string]string{"top": "h1", "sec": "a", "end": "div", "none": ""} {
+		n := anchor(nt, frag)
+		if exp == "" && n != nil || exp != "" && (n == nil || n.Data() != exp) {
+			t.Errorf("%v: %+v", frag, n)
+		}
+	}
+}
+
+func TestSameDocument(t *testing.T) {
+	a, _ := url.Parse("https://example.com/a.html#x")
+	b, _ := url.Parse("https://example.com/a.html#y")
+	c, _ := url.Parse("https://example.com/b.html#x")
+	if !sameDocument(a, b) || sameDocument(a, c) || sameDocument(a, nil) {
+		t.Fail()
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	for _, tt := range []struct {
+		from, to string
+		ok       bool
+	}{
+		{"http://example.com/", "https://example.com/", true},
+		{"https://example.com/", "http://example.com/", true},
+		{"file:///tmp", "file:///tmp/", true},
+		{"https://example.com/", "file:///etc/passwd", false},
+		{"http://example.com/", "gemini://example.com/", false},
+	} {
+		from, _ := http.NewRequest("GET", tt.from, nil)
+		to, _ := http.NewRequest("GET", tt.to, nil)
+		if err := checkRedirect(to, []*http.Request{from}); (err == nil) != tt.ok {
+			t.Errorf("%v -> %v: %v", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestLinkable(t *testing.T) {
+	f, _ := url.Parse("file:///tmp/a.html")
+	h, _ := url.Parse("https://example.com/")
+	b := Browser{}
+	if b.linkable(f) || !b.linkable(h) {
+		t.Fatalf("empty history")
+	}
+	b.History.Push(h, 0)
+	if b.linkable(f) || !b.linkable(h) {
+		t.Fatalf("from https")
+	}
+	b.History.Push(f, 0)
+	if !b.linkable(f) || !b.linkable(h) {
+		t.Fatalf("from file")
+	}
+}
+
+func TestStreamPartial(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<html><head><title>Partial</title></head><body><p id="first">first</p>`)
+		fmt.Fprintf(w, `<p>%v</p>`, strings.Repeat("x", firstScreenful))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		fmt.Fprintf(w, `<p id="last">last</p></body></html>`)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	b := newBrowser(srv.Client(), nil)
+	testDUI(t, b.Website)
+	go func() {
+		for range b.StatusCh {
+		}
+	}()
+	prev, _ := url.Parse(srv.URL + "/prev")
+	b.History.Push(prev, 0)
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	ct, _ := mycel.NewContentType("text/html", prev)
+	b.render(ct, []byte(`<html><body><p id="prev">previous page</p></body></html>`))
+
+	has := func(id string) bool {
+		ns, err := b.Website.nt.Query("#" + id)
+		return err == nil && len(ns) > 0
+	}
+	// state is changed by the loading goroutine and dui.Call
+	inUI := func(f func() bool) (ok bool) {
+		res := make(chan bool)
+		dui.Call <- func() { res <- f() }
+		return <-res
+	}
+	wait := func(what string, f func() bool) {
+		for i := 0; !inUI(f); i++ {
+			if i > 500 {
+				t.Fatalf("timeout waiting for %v", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	wait("previous page", func() bool { return has("prev") && !b.loading })
+
+	u, _ := url.Parse(srv.URL + "/page")
+	b.LoadUrl(u)
+	wait("partial layout", func() bool { return has("first") })
+	if !inUI(func() bool { return b.partial && b.loading && !has("prev") && !has("last") }) {
+		t.Fatalf("partial page")
+	}
+
+	b.Cancel()
+	wait("stop", func() bool { return !b.loading })
+	if !inUI(func() bool { return has("first") && b.Website.title == "Partial" }) {
+		t.Fatalf("node tree of the previous page kept")
+	}
+	b.Website.relayout()
+	if !has("first") || has("prev") {
+		t.Fatalf("relayout")
+	}
+}
+
+func TestSessionFiles(t *testing.T) {
+	old := sessionBase
+	t.Cleanup(func() { sessionBase = old })
+	sessionBase = t.TempDir() + "/history"
+	if fn := newSessionFile(); fn != sessionBase {
+		t.Fatalf("%v", fn)
+	}
+	for _, n := range []string{"history.10", "history.2", "history.1"} {
+		if err := os.WriteFile(filepath.Dir(sessionBase)+"/"+n, []byte("0\nhttp://example.com 0\n"), 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if fn := newSessionFile(); fn != sessionBase {
+		t.Fatalf("%v", fn)
+	}
+	os.WriteFile(sessionBase, []byte("x"), 0600)
+	if fn := newSessionFile(); fn != sessionBase+".3" {
+		t.Fatalf("%v", fn)
+	}
+	fns := sessionFiles()
+	if strings.Join(fns, " ") != strings.Join([]string{sessionBase, sessionBase + ".1", sessionBase + ".2", sessionBase + ".10"}, " ") {
+		t.Fatalf("%v", fns)
+	}
+	b := &Browser{restore: fns}
+	h, fn, ok := b.nextSession()
+	if !ok || fn != sessionBase+".1" || h.URL().String() != "http://example.com" {
+		t.Fatalf("%v %v %v", h.String(), fn, ok)
+	}
+	if _, err := os.Stat(sessionBase); !os.IsNotExist(err) {
+		t.Fatalf("broken session kept: %v", err)
+	}
+}
+
+func TestOpenCache(t *testing.T) {
+	n := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "<p>%d</p>", n)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL + "/doc")
+	b := &Browser{client: &http.Client{}, ctx: context.Background()}
+	for i := 0; i < 2; i++ {
+		buf, _, err := b.get(u, false)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if string(buf) != "<p>1</p>" {
+			t.Fatalf("%d: %s", i, buf)
+		}
+	}
+	if n != 1 {
+		t.Fatalf("%v requests", n)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	for in, exp := range map[string]string{
+		"9p.io":                "http://9p.io",
+		"https://9p.io/sys/":   "https://9p.io/sys/",
+		"/sys/doc":             "file:///sys/doc",
+		"gemini://example.com": "gemini://example.com",
+		"about:bookmarks":      "about:bookmarks",
+	} {
+		u, err := ParseLocation(in)
+		if err != nil || u.String() != exp {
+			t.Errorf("%v: %v %v", in, u, err)
+		}
+	}
+}
@@ -1,12 +1,37 @@
 package cache
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
 	"github.com/psilva261/mycel"
+	"github.com/psilva261/mycel/logger"
+	"io"
+	"net/http"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-var c = make(Items, 0, 100)
+// maxDiskAge after which unused items are removed from disk
+const maxDiskAge = 30 * 24 * time.Hour
+
+// maxDiskSize of the disk cache, least recently used items are
+// removed first
+var maxDiskSize int64 = 100 * 1024 * 1024
+
+var (
+	mu       sync.Mutex
+	c        = make(Items, 0, 100)
+	dir      string
+	lastTidy time.Time
+	written  int64 // bytes stored since the last tidy
+)
 
 type Items []*Item
 
@@ -25,29 +50,364 @@ func (is Items) Less(i, j int) bool {
 type Item struct {
 	Addr string
 	mycel.ContentType
-	Buf  []byte
-	Used time.Time
+	Header    http.Header // response header
+	ReqHeader http.Header // request header fields named by Vary
+	Buf       []byte      `json:"-"`
+	Stored    time.Time   // received or last revalidated
+	Used      time.Time
+}
+
+// SetDir enables the disk cache in d
+func SetDir(d string) (err error) {
+	if err = os.MkdirAll(d, 0700); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	dir = d
+	return
 }
 
-func Get(addr string) (i Item, ok bool) {
+// Get item matching the request's url and Vary header
+func Get(req *http.Request) (i Item, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	addr := req.URL.String()
 	for _, it := range c {
-		if it.Addr == addr {
+		if it.Addr == addr && it.matches(req) {
 			it.Used = time.Now()
 			return *it, true
 		}
 	}
+	rh := make(http.Header)
+	for _, k := range loadVary(addr) {
+		rh.Set(k, req.Header.Get(k))
+	}
+	it, err := load(key(addr, rh))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("cache: load %v: %v", addr, err)
+		}
+		return
+	}
+	if !it.matches(req) {
+		return
+	}
+	it.Used = time.Now()
+	c = append(c, it)
+	return *it, true
+}
+
+func (i Item) matches(req *http.Request) bool {
+	for k := range i.ReqHeader {
+		if i.ReqHeader.Get(k) != req.Header.Get(k) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewItem from a response to req, ok is false if it must not be stored.
+func NewItem(req *http.Request, resp *http.Response, buf []byte, ct mycel.ContentType) (i Item, ok bool) {
+	if req.Method != "GET" || resp.StatusCode != http.StatusOK {
+		return
+	}
+	if hasDirective(req.Header, "no-store") || hasDirective(resp.Header, "no-store") {
+		return
+	}
+	i = Item{
+		Addr:        req.URL.String(),
+		ContentType: ct,
+		Header:      resp.Header.Clone(),
+		ReqHeader:   make(http.Header),
+		Buf:         buf,
+		Stored:      time.Now(),
+	}
+	for _, v := range resp.Header.Values("Vary") {
+		for _, k := range strings.Split(v, ",") {
+			k = strings.TrimSpace(k)
+			if k == "*" {
+				return i, false
+			}
+			if k != "" {
+				i.ReqHeader.Set(k, req.Header.Get(k))
+			}
+		}
+	}
+	if i.lifetime() <= 0 && !i.validatable() {
+		return i, false
+	}
+	return i, true
+}
+
+// Fresh items can be used without revalidation
+func (i Item) Fresh(now time.Time) bool {
+	return i.age(now) < i.lifetime()
+}
+
+func (i Item) age(now time.Time) (a time.Duration) {
+	a = now.Sub(i.Stored)
+	if s, err := strconv.Atoi(i.Header.Get("Age")); err == nil && s > 0 {
+		a += time.Duration(s) * time.Second
+	}
 	return
 }
 
+func (i Item) lifetime() time.Duration {
+	if hasDirective(i.Header, "no-cache") {
+		return 0
+	}
+	if v, ok := directive(i.Header, "max-age"); ok {
+		s, err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+		return time.Duration(s) * time.Second
+	}
+	date, err := http.ParseTime(i.Header.Get("Date"))
+	if err != nil {
+		date = i.Stored
+	}
+	if e := i.Header.Get("Expires"); e != "" {
+		exp, err := http.ParseTime(e)
+		if err != nil {
+			// e.g. "0" means already expired
+			return 0
+		}
+		return exp.Sub(date)
+	}
+	if lm, err := http.ParseTime(i.Header.Get("Last-Modified")); err == nil && date.After(lm) {
+		// heuristic freshness
+		return date.Sub(lm) / 10
+	}
+	return 0
+}
+
+func (i Item) validatable() bool {
+	return i.Header.Get("ETag") != "" || i.Header.Get("Last-Modified") != ""
+}
+
+// SetValidators for a conditional request
+func (i Item) SetValidators(h http.Header) {
+	if etag := i.Header.Get("ETag"); etag != "" {
+		h.Set("If-None-Match", etag)
+	}
+	if lm := i.Header.Get("Last-Modified"); lm != "" {
+		h.Set("If-Modified-Since", lm)
+	}
+}
+
+// Revalidated updates i with the header of a 304 response
+func (i *Item) Revalidated(h http.Header) {
+	i.Header = i.Header.Clone()
+	for k, vs := range h {
+		switch http.CanonicalHeaderKey(k) {
+		case "Content-Length", "Content-Type", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		i.Header[k] = vs
+	}
+	i.Stored = time.Now()
+}
+
+func directive(h http.Header, name string) (v string, ok bool) {
+	for _, cc := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(cc, ",") {
+			d = strings.TrimSpace(strings.ToLower(d))
+			k, v, _ := strings.Cut(d, "=")
+			if k == name {
+				return strings.Trim(v, `"`), true
+			}
+		}
+	}
+	return
+}
+
+func hasDirective(h http.Header, name string) bool {
+	_, ok := directive(h, name)
+	return ok
+}
+
+// Set item in memory and on disk
 func Set(i Item) {
+	mu.Lock()
+	defer mu.Unlock()
+
 	i.Used = time.Now()
+	k := key(i.Addr, i.ReqHeader)
+	for j, it := range c {
+		if key(it.Addr, it.ReqHeader) == k {
+			c = append(c[:j], c[j+1:]...)
+			break
+		}
+	}
 	c = append(c, &i)
+	if err := store(&i); err != nil {
+		log.Errorf("cache: store %v: %v", i.Addr, err)
+	}
+	written += int64(len(i.Buf))
+	if dir != "" && written > maxDiskSize/10 {
+		written = 0
+		lastTidy = time.Now()
+		go tidyDisk(dir)
+	}
 }
 
+// Tidy keeps the most recently used items in memory and removes old
+// items from disk.
 func Tidy() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if dir != "" && time.Since(lastTidy) > time.Hour {
+		written = 0
+		lastTidy = time.Now()
+		go tidyDisk(dir)
+	}
 	if len(c) < 100 {
 		return
 	}
 	sort.Stable(c)
 	c = c[0:50]
 }
+
+// key of an item made of its url and the request header fields
+// named by Vary
+func key(addr string, reqHeader http.Header) string {
+	ks := make([]string, 0, len(reqHeader))
+	for k := range reqHeader {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		addr += "\n" + k + ": " + reqHeader.Get(k)
+	}
+	return addr
+}
+
+func filename(key string) string {
+	return fmt.Sprintf("%s/%x", dir, sha1.Sum([]byte(key)))
+}
+
+// storeVary writes the field names of the Vary header next to the
+// items of addr. Without Vary the item is stored under addr alone.
+func storeVary(addr string, reqHeader http.Header) error {
+	fn := filename(addr) + ".vary"
+	if len(reqHeader) == 0 {
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	ks := make([]string, 0, len(reqHeader))
+	for k := range reqHeader {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return os.WriteFile(fn, []byte(strings.Join(ks, "\n")), 0600)
+}
+
+// loadVary returns the field names to select the item of addr
+func loadVary(addr string) []string {
+	if dir == "" {
+		return nil
+	}
+	fn := filename(addr) + ".vary"
+	bs, err := os.ReadFile(fn)
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+	os.Chtimes(fn, now, now)
+	return strings.Fields(string(bs))
+}
+
+// store the item as a line of json followed by the body
+func store(i *Item) (err error) {
+	if dir == "" {
+		return
+	}
+	meta, err := json.Marshal(i)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	if err = storeVary(i.Addr, i.ReqHeader); err != nil {
+		return fmt.Errorf("store vary: %w", err)
+	}
+	fn := filename(key(i.Addr, i.ReqHeader))
+	f, err := os.Create(fn + ".tmp")
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	w.Write(meta)
+	w.WriteString("\n")
+	w.Write(i.Buf)
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	return os.Rename(fn+".tmp", fn)
+}
+
+func load(key string) (i *Item, err error) {
+	if dir == "" {
+		return nil, os.ErrNotExist
+	}
+	fn := filename(key)
+	bs, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(bytes.NewReader(bs))
+	meta, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read meta: %w", err)
+	}
+	i = &Item{}
+	if err = json.Unmarshal(meta, i); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if i.Buf, err = io.ReadAll(r); err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	now := time.Now()
+	os.Chtimes(fn, now, now)
+	return
+}
+
+// tidyDisk removes items not used for maxDiskAge and then the least
+// recently used ones until the cache fits into maxDiskSize
+func tidyDisk(d string) {
+	es, err := os.ReadDir(d)
+	if err != nil {
+		log.Errorf("cache: read dir: %v", err)
+		return
+	}
+	fis := make([]os.FileInfo, 0, len(es))
+	for _, e := range es {
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(fi.ModTime()) > maxDiskAge {
+			os.Remove(d + "/" + e.Name())
+		} else {
+			fis = append(fis, fi)
+		}
+	}
+	sort.Slice(fis, func(i, j int) bool {
+		return fis[i].ModTime().After(fis[j].ModTime())
+	})
+	var size int64
+	for _, fi := range fis {
+		size += fi.Size()
+		if size > maxDiskSize {
+			os.Remove(d + "/" + fi.Name())
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"github.com/psilva261/mycel"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func response(h map[string]string) (req *http.Request, resp *http.Response) {
+	req, _ = http.NewRequest("GET", "https://example.com/a.css", nil)
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+	}
+	for k, v := range h {
+		resp.Header.Set(k, v)
+	}
+	return
+}
+
+func TestFresh(t *testing.T) {
+	now := time.Now()
+	date := now.UTC().Format(http.TimeFormat)
+	tests := []struct {
+		h     map[string]string
+		ok    bool
+		fresh bool
+	}{
+		{map[string]string{"Cache-Control": "max-age=60"}, true, true},
+		{map[string]string{"Cache-Control": "max-age=0", "ETag": `"1"`}, true, false},
+		{map[string]string{"Cache-Control": "no-store, max-age=60"}, false, false},
+		{map[string]string{"Cache-Control": "no-cache", "ETag": `"1"`}, true, false},
+		{map[string]string{"Date": date, "Expires": now.Add(time.Hour).UTC().Format(http.TimeFormat)}, true, true},
+		{map[string]string{"Date": date, "Expires": "0"}, false, false},
+		{map[string]string{"Date": date, "Last-Modified": now.Add(-100 * time.Hour).UTC().Format(http.TimeFormat)}, true, true},
+		{map[string]string{}, false, false},
+		{map[string]string{"Cache-Control": "max-age=60", "Vary": "*"}, false, true},
+	}
+	for _, tt := range tests {
+		req, resp := response(tt.h)
+		i, ok := NewItem(req, resp, nil, mycel.ContentType{})
+		if ok != tt.ok {
+			t.Errorf("%+v: ok=%v", tt.h, ok)
+		}
+		if f := i.Fresh(now.Add(time.Second)); f != tt.fresh {
+			t.Errorf("%+v: fresh=%v", tt.h, f)
+		}
+	}
+}
+
+func TestVary(t *testing.T) {
+	req, resp := response(map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Language"})
+	req.Header.Set("Accept-Language", "de")
+	i, ok := NewItem(req, resp, nil, mycel.ContentType{})
+	if !ok {
+		t.Fatalf("not ok")
+	}
+	Set(i)
+	if _, ok := Get(req); !ok {
+		t.Fatalf("no item")
+	}
+	req.Header.Set("Accept-Language", "en")
+	if _, ok := Get(req); ok {
+		t.Fatalf("unexpected match")
+	}
+}
+
+func TestRevalidated(t *testing.T) {
+	req, resp := response(map[string]string{"Cache-Control": "max-age=0", "ETag": `"1"`, "Last-Modified": "Mon, 02 Jan 2006 15:04:05 GMT"})
+	i, ok := NewItem(req, resp, nil, mycel.ContentType{})
+	if !ok {
+		t.Fatalf("not ok")
+	}
+	h := make(http.Header)
+	i.SetValidators(h)
+	if h.Get("If-None-Match") != `"1"` || h.Get("If-Modified-Since") == "" {
+		t.Fatalf("%+v", h)
+	}
+	i.Revalidated(http.Header{"Cache-Control": []string{"max-age=60"}})
+	if !i.Fresh(time.Now()) {
+		t.Fatalf("%+v", i)
+	}
+}
+
+func TestDisk(t *testing.T) {
+	if err := SetDir(t.TempDir()); err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer func() {
+		dir = ""
+	}()
+	req, resp := response(map[string]string{"Cache-Control": "max-age=60"})
+	ct := mycel.ContentType{MediaType: "text/css"}
+	i, ok := NewItem(req, resp, []byte("body {}"), ct)
+	if !ok {
+		t.Fatalf("not ok")
+	}
+	Set(i)
+	c = c[:0]
+	ii, ok := Get(req)
+	if !ok {
+		t.Fatalf("not on disk")
+	}
+	if string(ii.Buf) != "body {}" || ii.MediaType != "text/css" || !ii.Fresh(time.Now()) {
+		t.Fatalf("%+v", ii)
+	}
+}
+
+func TestDiskVary(t *testing.T) {
+	if err := SetDir(t.TempDir()); err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer func() {
+		dir = ""
+	}()
+	for _, l := range []string{"de", "en"} {
+		req, resp := response(map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Language"})
+		req.Header.Set("Accept-Language", l)
+		i, ok := NewItem(req, resp, []byte(l), mycel.ContentType{})
+		if !ok {
+			t.Fatalf("not ok")
+		}
+		Set(i)
+	}
+	c = c[:0]
+	for _, l := range []string{"de", "en", "fr"} {
+		req, _ := response(nil)
+		req.Header.Set("Accept-Language", l)
+		i, ok := Get(req)
+		if l == "fr" {
+			if ok {
+				t.Fatalf("unexpected match %+v", i)
+			}
+		} else if !ok || string(i.Buf) != l {
+			t.Fatalf("%v: %+v %v", l, i, ok)
+		}
+	}
+}
+
+func TestTidyDisk(t *testing.T) {
+	d := t.TempDir()
+	old := maxDiskSize
+	maxDiskSize = 10
+	defer func() {
+		maxDiskSize = old
+	}()
+	now := time.Now()
+	for i, n := range []string{"a", "b", "c"} {
+		fn := d + "/" + n
+		if err := os.WriteFile(fn, []byte("12345"), 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+		mt := now.Add(-time.Duration(i) * time.Minute)
+		os.Chtimes(fn, mt, mt)
+	}
+	tidyDisk(d)
+	for _, n := range []string{"a", "b"} {
+		if _, err := os.Stat(d + "/" + n); err != nil {
+			t.Fatalf("%v: %v", n, err)
+		}
+	}
+	if _, err := os.Stat(d + "/c"); !os.IsNotExist(err) {
+		t.Fatalf("least recently used item kept: %v", err)
+	}
+}
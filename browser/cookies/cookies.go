@@ -0,0 +1,331 @@
+// Package cookies implements a cookie jar that keeps persistent
+// cookies in a Netscape cookies.txt file.
+package cookies
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/psilva261/mycel/logger"
+	"golang.org/x/net/publicsuffix"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// Jar of cookies. Matching is done by net/http/cookiejar, the
+// entries are tracked additionally so that they can be listed
+// and saved.
+type Jar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	entries map[string]entry
+	fn      string
+}
+
+type entry struct {
+	Domain   string
+	HostOnly bool
+	Path     string
+	Secure   bool
+	HttpOnly bool
+	Expires  time.Time // zero for session cookies
+	Name     string
+	Value    string
+}
+
+func (e entry) key() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+func (e entry) persistent() bool {
+	return !e.Expires.IsZero()
+}
+
+func (e entry) url() *url.URL {
+	return &url.URL{Scheme: "https", Host: e.Domain, Path: e.Path}
+}
+
+func (e entry) cookie() (c *http.Cookie) {
+	c = &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Path:     e.Path,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+		Expires:  e.Expires,
+	}
+	if !e.HostOnly {
+		c.Domain = e.Domain
+	}
+	return
+}
+
+// New Jar which loads and saves persistent cookies in the file fn.
+// With fn empty nothing is stored on disk.
+func New(fn string) (j *Jar, err error) {
+	cj, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, fmt.Errorf("cookiejar: %w", err)
+	}
+	j = &Jar{
+		jar:     cj,
+		entries: make(map[string]entry),
+	}
+	if fn == "" {
+		return
+	}
+	f, err := os.Open(fn)
+	if err == nil {
+		defer f.Close()
+		if err = j.Load(f); err != nil {
+			return nil, fmt.Errorf("load: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	j.fn = fn
+	return j, nil
+}
+
+// SetCookies implements http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar.SetCookies(u, cookies)
+	changed := false
+	now := time.Now()
+	for _, c := range cookies {
+		e, ok := newEntry(u, c, now)
+		if !ok {
+			continue
+		}
+		old, had := j.entries[e.key()]
+		if e.Expires.Before(now) && e.persistent() {
+			delete(j.entries, e.key())
+			changed = changed || (had && old.persistent())
+			continue
+		}
+		j.entries[e.key()] = e
+		changed = changed || e.persistent() || (had && old.persistent())
+	}
+	if changed {
+		j.save()
+	}
+}
+
+// Cookies implements http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// newEntry for c received from u. ok is false if c would be
+// rejected by the jar.
+func newEntry(u *url.URL, c *http.Cookie, now time.Time) (e entry, ok bool) {
+	host := strings.ToLower(u.Hostname())
+	e = entry{
+		Domain:   host,
+		HostOnly: true,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		Name:     c.Name,
+		Value:    c.Value,
+	}
+	if d := strings.TrimPrefix(strings.ToLower(c.Domain), "."); d != "" && d != host {
+		if !strings.HasSuffix(host, "."+d) {
+			return e, false
+		}
+		if ps, _ := publicsuffix.PublicSuffix(d); ps == d {
+			return e, false
+		}
+		e.Domain = d
+		e.HostOnly = false
+	} else if d != "" {
+		e.HostOnly = false
+	}
+	if !strings.HasPrefix(e.Path, "/") {
+		e.Path = defaultPath(u.Path)
+	}
+	if c.MaxAge < 0 {
+		e.Expires = time.Unix(1, 0)
+	} else if c.MaxAge > 0 {
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	} else {
+		e.Expires = c.Expires
+	}
+	return e, true
+}
+
+// defaultPath as in RFC 6265 section 5.1.4
+func defaultPath(p string) string {
+	i := strings.LastIndex(p, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// WriteTo writes all cookies including session cookies in
+// cookies.txt format. Session cookies have an expiry of 0.
+func (j *Jar) WriteTo(w io.Writer) (n int64, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.write(w, false)
+}
+
+func (j *Jar) write(w io.Writer, persistentOnly bool) (n int64, err error) {
+	now := time.Now()
+	es := make([]entry, 0, len(j.entries))
+	for k, e := range j.entries {
+		if e.persistent() && e.Expires.Before(now) {
+			delete(j.entries, k)
+			continue
+		}
+		if persistentOnly && !e.persistent() {
+			continue
+		}
+		es = append(es, e)
+	}
+	sort.Slice(es, func(i, k int) bool {
+		return es[i].key() < es[k].key()
+	})
+	for _, e := range es {
+		m, err := io.WriteString(w, e.String()+"\n")
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return
+}
+
+// String in cookies.txt format
+func (e entry) String() string {
+	d := e.Domain
+	if !e.HostOnly {
+		d = "." + d
+	}
+	if e.HttpOnly {
+		d = httpOnlyPrefix + d
+	}
+	var exp int64
+	if e.persistent() {
+		exp = e.Expires.Unix()
+	}
+	return strings.Join([]string{
+		d,
+		upper(!e.HostOnly),
+		e.Path,
+		upper(e.Secure),
+		strconv.FormatInt(exp, 10),
+		e.Name,
+		e.Value,
+	}, "\t")
+}
+
+func upper(b bool) string {
+	return strings.ToUpper(strconv.FormatBool(b))
+}
+
+// Load cookies in cookies.txt format. Cookies with an expiry in
+// the past are deleted, an expiry of 0 sets a session cookie.
+func (j *Jar) Load(r io.Reader) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	sc := bufio.NewScanner(r)
+	changed := false
+	for i := 1; sc.Scan(); i++ {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || (strings.HasPrefix(l, "#") && !strings.HasPrefix(l, httpOnlyPrefix)) {
+			continue
+		}
+		e, err := parse(l)
+		if err != nil {
+			return fmt.Errorf("line %v: %w", i, err)
+		}
+		old, had := j.entries[e.key()]
+		c := e.cookie()
+		if e.persistent() && e.Expires.Before(time.Now()) {
+			c.MaxAge = -1
+			delete(j.entries, e.key())
+		} else {
+			j.entries[e.key()] = e
+		}
+		j.jar.SetCookies(e.url(), []*http.Cookie{c})
+		changed = changed || e.persistent() || (had && old.persistent())
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if changed {
+		j.save()
+	}
+	return nil
+}
+
+func parse(l string) (e entry, err error) {
+	fs := strings.Split(l, "\t")
+	if len(fs) == 6 {
+		// empty value
+		fs = append(fs, "")
+	}
+	if len(fs) != 7 {
+		return e, fmt.Errorf("%v fields", len(fs))
+	}
+	d := fs[0]
+	if strings.HasPrefix(d, httpOnlyPrefix) {
+		d = strings.TrimPrefix(d, httpOnlyPrefix)
+		e.HttpOnly = true
+	}
+	e.HostOnly = fs[1] != "TRUE"
+	e.Domain = strings.ToLower(strings.TrimPrefix(d, "."))
+	e.Path = fs[2]
+	e.Secure = fs[3] == "TRUE"
+	exp, err := strconv.ParseInt(fs[4], 10, 64)
+	if err != nil {
+		return e, fmt.Errorf("expiry: %w", err)
+	}
+	if exp > 0 {
+		e.Expires = time.Unix(exp, 0)
+	}
+	e.Name = fs[5]
+	e.Value = fs[6]
+	if e.Domain == "" || e.Name == "" {
+		return e, fmt.Errorf("domain and name required")
+	}
+	if !strings.HasPrefix(e.Path, "/") {
+		e.Path = "/"
+	}
+	return
+}
+
+// save persistent cookies, session cookies are dropped
+func (j *Jar) save() {
+	if j.fn == "" {
+		return
+	}
+	f, err := os.OpenFile(j.fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Errorf("create %v: %v", j.fn, err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# Netscape HTTP Cookie File\n\n")
+	if _, err := j.write(w, true); err != nil {
+		log.Errorf("write %v: %v", j.fn, err)
+		return
+	}
+	if err := w.Flush(); err != nil {
+		log.Errorf("write %v: %v", j.fn, err)
+	}
+}
@@ -0,0 +1,78 @@
+package cookies
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPersist(t *testing.T) {
+	fn := t.TempDir() + "/cookies.txt"
+	j, err := New(fn)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	u, _ := url.Parse("https://www.example.com/app/login")
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "1"},
+		{Name: "login", Value: "2", Domain: "example.com", Path: "/", Expires: exp, HttpOnly: true},
+		{Name: "other", Value: "3", Domain: "other.com", MaxAge: 3600},
+		{Name: "tmp", Value: "4", MaxAge: 3600},
+	})
+	j.SetCookies(u, []*http.Cookie{{Name: "tmp", MaxAge: -1}})
+	var buf bytes.Buffer
+	if _, err := j.WriteTo(&buf); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if s := buf.String(); !strings.Contains(s, "www.example.com\tFALSE\t/app\tFALSE\t0\tsession\t1\n") || strings.Contains(s, "other") || strings.Contains(s, "tmp") {
+		t.Fatalf("%v", s)
+	}
+
+	j, err = New(fn)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	cs := j.Cookies(u)
+	if len(cs) != 1 || cs[0].Name != "login" || cs[0].Value != "2" {
+		t.Fatalf("%+v", cs)
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	l := "#HttpOnly_.example.com\tTRUE\t/\tFALSE\t" + strconv.FormatInt(exp.Unix(), 10) + "\tlogin\t2\n"
+	if !strings.Contains(string(data), l) {
+		t.Fatalf("%v", string(data))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	j, err := New("")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = j.Load(strings.NewReader("# comment\n.example.com\tTRUE\t/\tTRUE\t0\ta\tb\n"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	u, _ := url.Parse("https://sub.example.com/x")
+	if cs := j.Cookies(u); len(cs) != 1 || cs[0].Value != "b" {
+		t.Fatalf("%+v", cs)
+	}
+	// expiry in the past deletes
+	if err := j.Load(strings.NewReader(".example.com\tTRUE\t/\tTRUE\t1\ta\t\n")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if cs := j.Cookies(u); len(cs) != 0 {
+		t.Fatalf("%+v", cs)
+	}
+	if err := j.Load(strings.NewReader("example.com\tTRUE\n")); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,144 @@
+package browser
+
+import (
+	"fmt"
+	"github.com/mjl-/duit"
+	"strconv"
+	"strings"
+)
+
+// ctlCmds executed by Ctl. The argument is already checked
+// when needed.
+var ctlCmds = map[string]struct {
+	arg bool
+	f   func(b *Browser, arg string) error
+}{
+	"open": {true, func(b *Browser, arg string) error {
+		u, err := ParseLocation(arg)
+		if err != nil {
+			return fmt.Errorf("parse: %w", err)
+		}
+		if b.loading {
+			return fmt.Errorf("page is loading")
+		}
+		b.SetAndLoadUrl(u)()
+		return nil
+	}},
+	"back": {false, func(b *Browser, _ string) error {
+		b.Back()
+		return nil
+	}},
+	"forward": {false, func(b *Browser, _ string) error {
+		b.Forward()
+		return nil
+	}},
+	"reload": {false, func(b *Browser, _ string) error {
+		b.LoadUrl(b.URL())
+		return nil
+	}},
+	"stop": {false, func(b *Browser, _ string) error {
+		if b.cancel != nil {
+			b.Cancel()
+		}
+		return nil
+	}},
+	"scroll": {true, func(b *Browser, arg string) error {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("scroll: %w", err)
+		}
+		if b.scroller == nil {
+			return fmt.Errorf("no page")
+		}
+		b.scroller.SetOffset(b.scroller.Offset + dui.Scale(n))
+		dui.MarkDraw(b.scroller)
+		return nil
+	}},
+	"click": {true, func(b *Browser, arg string) error {
+		el, err := b.element(arg)
+		if err != nil {
+			return err
+		}
+		el.click()
+		return nil
+	}},
+	"find": {true, func(b *Browser, arg string) error {
+		find := b.Find
+		if b.FindText != nil {
+			find = b.FindText
+		}
+		if find(arg) == 0 {
+			return fmt.Errorf("not found")
+		}
+		return nil
+	}},
+	"zoom": {true, func(b *Browser, arg string) error {
+		delta, ok := map[string]int{"in": 1, "out": -1, "reset": 0}[arg]
+		if !ok {
+			return fmt.Errorf("zoom: expected in, out or reset")
+		}
+		b.Zoom(delta)
+		return nil
+	}},
+	"snarf": {false, func(b *Browser, _ string) error {
+		s := selectedText(b.Website.UI)
+		if s == "" {
+			s = b.URL().String()
+		}
+		dui.WriteSnarf([]byte(s))
+		return nil
+	}},
+}
+
+// Ctl executes a command written to the ctl file:
+//
+//	open URL        fails while a page is loading
+//	back
+//	forward
+//	reload
+//	stop
+//	scroll N        scroll down N pixels, up if N is negative
+//	click SELECTOR  click the first element matching SELECTOR
+//	find TEXT
+//	zoom in|out|reset
+//	snarf           copy the selected text or else the URL
+func (b *Browser) Ctl(cmd string) error {
+	name, arg, _ := strings.Cut(strings.TrimSpace(cmd), " ")
+	arg = strings.TrimSpace(arg)
+	c, ok := ctlCmds[name]
+	if !ok {
+		return fmt.Errorf("unknown command %v", name)
+	}
+	if c.arg && arg == "" {
+		return fmt.Errorf("%v: argument missing", name)
+	} else if !c.arg && arg != "" {
+		return fmt.Errorf("%v: unexpected argument", name)
+	}
+	res := make(chan error, 1)
+	dui.Call <- func() {
+		res <- c.f(b, arg)
+	}
+	return <-res
+}
+
+// element displaying the first node matching sel
+func (b *Browser) element(sel string) (el *Element, err error) {
+	if b.Website.nt == nil {
+		return nil, fmt.Errorf("no page")
+	}
+	ns, err := b.Website.nt.Query(sel)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	for _, n := range ns {
+		TraverseTree(b.Website.UI, func(ui duit.UI) {
+			if e, ok := ui.(*Element); ok && e != nil && el == nil && e.n == n {
+				el = e
+			}
+		})
+		if el != nil {
+			return
+		}
+	}
+	return nil, fmt.Errorf("no element matches %v", sel)
+}
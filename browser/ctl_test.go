@@ -0,0 +1,27 @@
+package browser
+
+import (
+	"testing"
+)
+
+func TestCtlArgs(t *testing.T) {
+	b := &Browser{}
+	for _, cmd := range []string{
+		"",
+		"jump 3",
+		"open",
+		"back 2",
+		"click  ",
+	} {
+		if err := b.Ctl(cmd); err == nil {
+			t.Errorf("%v: expected error", cmd)
+		}
+	}
+}
+
+func TestCtlOpenLoading(t *testing.T) {
+	b := &Browser{loading: true}
+	if err := ctlCmds["open"].f(b, "https://example.com"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,240 @@
+// Package downloads streams responses to files and keeps a list of
+// active and finished transfers.
+package downloads
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// URL of the generated downloads page
+const URL = "about:downloads"
+
+type Download struct {
+	ID   int
+	URL  string
+	File string
+	Size int64 // -1 if unknown
+
+	n      atomic.Int64
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error // set when done is closed
+}
+
+var (
+	mu  sync.RWMutex
+	dls []*Download
+)
+
+// Name suggested for the response to u based on Content-Disposition
+// or the last element of the URL path
+func Name(h http.Header, u *url.URL) (n string) {
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil {
+		n = params["filename"]
+	}
+	if n == "" && u != nil {
+		n = path.Base(u.Path)
+	}
+	// neither leave the download directory nor hide the file
+	n = strings.TrimLeft(filepath.Base(strings.ReplaceAll(n, "\\", "/")), ".")
+	if n == "" || n == "/" {
+		n = "download"
+	}
+	return
+}
+
+// Dir where downloads are suggested to be saved, the Downloads
+// folder in home if there is one
+func Dir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return os.TempDir()
+	}
+	if fi, err := os.Stat(filepath.Join(home, "Downloads")); err == nil && fi.IsDir() {
+		return filepath.Join(home, "Downloads")
+	}
+	return home
+}
+
+// Unique variant of the path fn that doesn't exist yet like
+// file-1.zip
+func Unique(fn string) string {
+	ext := filepath.Ext(fn)
+	base := strings.TrimSuffix(fn, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(fn); os.IsNotExist(err) {
+			return fn
+		}
+		fn = fmt.Sprintf("%v-%d%v", base, i, ext)
+	}
+}
+
+// Start copying body to the new file fn in the background. Existing
+// files are never overwritten. The body is closed when done.
+func Start(u *url.URL, body io.ReadCloser, size int64, fn string) (d *Download, err error) {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d = &Download{
+		URL:    u.String(),
+		File:   fn,
+		Size:   size,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	mu.Lock()
+	d.ID = len(dls) + 1
+	dls = append(dls, d)
+	mu.Unlock()
+	stop := context.AfterFunc(ctx, func() {
+		body.Close()
+	})
+	go func() {
+		_, err := io.Copy(f, &counter{r: body, n: &d.n})
+		stop()
+		body.Close()
+		if ctx.Err() != nil {
+			err = fmt.Errorf("cancelled")
+		}
+		if er := f.Close(); err == nil && er != nil {
+			err = er
+		}
+		if err != nil {
+			os.Remove(fn)
+		}
+		cancel()
+		d.err = err
+		close(d.done)
+	}()
+	return
+}
+
+type counter struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *counter) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n.Add(int64(n))
+	return
+}
+
+// Written bytes so far
+func (d *Download) Written() int64 {
+	return d.n.Load()
+}
+
+// Done is closed when the download has finished or failed
+func (d *Download) Done() <-chan struct{} {
+	return d.done
+}
+
+// Err after Done is closed
+func (d *Download) Err() error {
+	select {
+	case <-d.done:
+		return d.err
+	default:
+		return nil
+	}
+}
+
+// Cancel the download and remove the partial file
+func (d *Download) Cancel() {
+	d.cancel()
+}
+
+// Status like "1.2 MB of 3.0 MB", "done" or the error
+func (d *Download) Status() string {
+	select {
+	case <-d.done:
+		if d.err != nil {
+			return d.err.Error()
+		}
+		return "done " + bytesize(d.Written())
+	default:
+	}
+	if d.Size < 0 {
+		return bytesize(d.Written())
+	}
+	p := 0
+	if d.Size > 0 {
+		p = int(100 * d.Written() / d.Size)
+	}
+	return fmt.Sprintf("%v of %v (%d%%)", bytesize(d.Written()), bytesize(d.Size), p)
+}
+
+func bytesize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// All downloads of this session, oldest first
+func All() []*Download {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]*Download{}, dls...)
+}
+
+// Cancel download with the id
+func Cancel(id int) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if id < 1 || id > len(dls) {
+		return fmt.Errorf("no download %v", id)
+	}
+	dls[id-1].Cancel()
+	return nil
+}
+
+// Text with one download per line:
+//
+// 1 https://example.com/a.zip /home/glenda/a.zip done 1.2 MB
+func Text() []byte {
+	var buf bytes.Buffer
+	for _, d := range All() {
+		fmt.Fprintf(&buf, "%v %v %v %v\n", d.ID, d.URL, d.File, d.Status())
+	}
+	return buf.Bytes()
+}
+
+// HTML page listing the downloads with links to cancel active ones
+func HTML() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n<html><head><title>Downloads</title></head><body>\n<h1>Downloads</h1>\n<ul>\n")
+	for _, d := range All() {
+		fmt.Fprintf(&buf, "<li><a href=\"%v\">%v</a> %v", html.EscapeString(d.URL), html.EscapeString(d.File), html.EscapeString(d.Status()))
+		select {
+		case <-d.done:
+		default:
+			fmt.Fprintf(&buf, " <a href=\"%v?cancel=%d\">cancel</a>", URL, d.ID)
+		}
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</ul>\n</body></html>\n")
+	return buf.Bytes()
+}
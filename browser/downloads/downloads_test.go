@@ -0,0 +1,86 @@
+package downloads
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	u, _ := url.Parse("https://example.com/dl/a%20b.zip?x=1")
+	for cd, exp := range map[string]string{
+		"":                                      "a b.zip",
+		`attachment; filename="report.pdf"`:     "report.pdf",
+		`attachment; filename="../../.profile"`: "profile",
+		`attachment; filename*=UTF-8''%C3%A4.txt`: "ä.txt",
+	} {
+		h := make(http.Header)
+		h.Set("Content-Disposition", cd)
+		if n := Name(h, u); n != exp {
+			t.Fatalf("%v: %v", cd, n)
+		}
+	}
+	u, _ = url.Parse("https://example.com/")
+	if n := Name(make(http.Header), u); n != "download" {
+		t.Fatalf("%v", n)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	d := t.TempDir()
+	for _, fn := range []string{"a.zip", "a-1.zip"} {
+		if err := os.WriteFile(d+"/"+fn, nil, 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if fn := Unique(d + "/a.zip"); fn != d+"/a-2.zip" {
+		t.Fatalf("%v", fn)
+	}
+	if fn := Unique(d + "/b.zip"); fn != d+"/b.zip" {
+		t.Fatalf("%v", fn)
+	}
+}
+
+func TestStart(t *testing.T) {
+	d := t.TempDir()
+	u, _ := url.Parse("https://example.com/a.txt")
+	dl, err := Start(u, io.NopCloser(strings.NewReader("hello")), 5, d+"/a.txt")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	<-dl.Done()
+	if err := dl.Err(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if buf, err := os.ReadFile(d + "/a.txt"); err != nil || string(buf) != "hello" {
+		t.Fatalf("%q %v", buf, err)
+	}
+	if _, err := Start(u, io.NopCloser(strings.NewReader("again")), 5, d+"/a.txt"); err == nil {
+		t.Fatalf("existing file overwritten")
+	}
+
+	r, w := io.Pipe()
+	dl, err = Start(u, r, -1, d+"/b.txt")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	w.Write([]byte("partial"))
+	if err := Cancel(dl.ID); err != nil {
+		t.Fatalf("%v", err)
+	}
+	<-dl.Done()
+	if dl.Err() == nil {
+		t.Fatalf("not cancelled")
+	}
+	if _, err := os.Stat(d + "/b.txt"); !os.IsNotExist(err) {
+		t.Fatalf("partial file kept: %v", err)
+	}
+	txt := string(Text())
+	if !strings.Contains(txt, "1 https://example.com/a.txt "+d+"/a.txt done 5 B\n") ||
+		!strings.Contains(txt, "2 https://example.com/a.txt "+d+"/b.txt cancelled\n") {
+		t.Fatalf("%v", txt)
+	}
+}
@@ -31,6 +31,8 @@ import (
 
 var (
 	selectedBg *draw.Image
+	foundBg    *draw.Image
+	currentBg  *draw.Image
 )
 
 // Label draws multiline text in a single font.:
@@ -43,6 +45,8 @@ type Label struct {
 	Font     *draw.Font            `json:"-"` // For drawing text.
 	Click    func() (e duit.Event) `json:"-"` // Called on button1 click.
 	Selected bool
+	Found    bool // matches a search
+	Current  bool // current search match
 
 	orig  image.Point
 	size  image.Point
@@ -79,11 +83,24 @@ func (ui *Label) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image
 		if err != nil {
 			panic(fmt.Errorf("%v", err))
 		}
+		foundBg, err = dui.Display.AllocImage(image.Rect(0, 0, 10, 10), draw.ARGB32, true, 0xffff00ff)
+		if err != nil {
+			panic(fmt.Errorf("%v", err))
+		}
+		currentBg, err = dui.Display.AllocImage(image.Rect(0, 0, 10, 10), draw.ARGB32, true, 0xff8c00ff)
+		if err != nil {
+			panic(fmt.Errorf("%v", err))
+		}
 	}
 
+	// highlighted text is black to be readable in dark mode too
 	font := ui.font(dui)
 	if ui.Selected {
-		img.StringBg(orig, dui.Regular.Normal.Text, image.ZP, font, ui.Text, selectedBg, image.ZP)
+		img.StringBg(orig, dui.Display.Black, image.ZP, font, ui.Text, selectedBg, image.ZP)
+	} else if ui.Current {
+		img.StringBg(orig, dui.Display.Black, image.ZP, font, ui.Text, currentBg, image.ZP)
+	} else if ui.Found {
+		img.StringBg(orig, dui.Display.Black, image.ZP, font, ui.Text, foundBg, image.ZP)
 	} else {
 		img.String(orig, dui.Regular.Normal.Text, image.ZP, font, ui.Text)
 	}
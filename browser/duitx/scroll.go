@@ -71,6 +71,39 @@ func (ui *Scroll) Free() {
 	ui.last = make(map[int]time.Time)
 }
 
+// Redraw discards all tiles, e.g. when the child changed
+// without being marked
+func (ui *Scroll) Redraw() {
+	for i, tl := range ui.tiles {
+		tl.Free()
+		delete(ui.tiles, i)
+		delete(ui.last, i)
+	}
+}
+
+// Show scrolls such that r (in child coordinates) is visible,
+// changed is false if r is visible already.
+func (ui *Scroll) Show(dui *duit.DUI, r image.Rectangle) (changed bool) {
+	o := ui.Offset
+	if r.Min.Y < ui.Offset {
+		ui.SetOffset(r.Min.Y - dui.Scale(40))
+	} else if r.Max.Y > ui.Offset+ui.childR.Dy() {
+		ui.SetOffset(r.Max.Y + dui.Scale(40) - ui.childR.Dy())
+	}
+	return o != ui.Offset
+}
+
+// Visible part of the child
+func (ui *Scroll) Visible() image.Rectangle {
+	return image.Rect(0, ui.Offset, ui.childR.Dx(), ui.Offset+ui.childR.Dy())
+}
+
+// SetOffset limited to the scrollable range
+func (ui *Scroll) SetOffset(y int) {
+	ui.Offset = minimum(y, maximum(0, ui.Kid.R.Dy()-ui.childR.Dy()))
+	ui.Offset = maximum(0, ui.Offset)
+}
+
 func (ui *Scroll) freeCur() {
 	i, of := ui.pos()
 	tl, ok := ui.tiles[i]
@@ -344,10 +377,33 @@ func (ui *Scroll) scrollKey(k rune) (consumed bool) {
 		return ui.scroll(-200)
 	case draw.KeyPageDown:
 		return ui.scroll(200)
+	case draw.KeyHome:
+		return ui.scroll(-ui.Offset)
+	case draw.KeyEnd:
+		return ui.scroll(ui.Kid.R.Dy())
 	}
 	return false
 }
 
+// viKey maps vi-like scroll keys to the keys scrollKey handles
+func viKey(k rune) rune {
+	switch k {
+	case 'j':
+		return draw.KeyDown
+	case 'k':
+		return draw.KeyUp
+	case ' ':
+		return draw.KeyPageDown
+	case 'b':
+		return draw.KeyPageUp
+	case 'g':
+		return draw.KeyHome
+	case 'G':
+		return draw.KeyEnd
+	}
+	return 0
+}
+
 func (ui *Scroll) scrollMouse(m draw.Mouse, scrollOnly bool) (consumed bool) {
 	switch m.Buttons {
 	case duit.Button4:
@@ -441,6 +497,12 @@ func (ui *Scroll) Key(dui *duit.DUI, self *duit.Kid, k rune, m draw.Mouse, orig
 			return
 		}
 		r = ui.Kid.UI.Key(dui, &ui.Kid, k, m, image.ZP)
+		if !r.Consumed && ui.scrollKey(viKey(k)) {
+			// vi-like keys unless used by the child, e.g. a field
+			self.Draw = duit.Dirty
+			r.Consumed = true
+			return
+		}
 		ui.warpScroll(dui, self, r.Warp, orig)
 		ui.result(dui, self, &r, scrolled)
 		log.Printf("Key: in ui.childR (self.Draw'=%v)", self.Draw)
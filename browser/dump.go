@@ -0,0 +1,250 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"github.com/psilva261/mycel/browser/cookies"
+	"github.com/psilva261/mycel/nodes"
+	"github.com/psilva261/mycel/style"
+	"golang.org/x/net/html"
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Dump writes the visible text of the website at u to w,
+// wrapped at cols columns.
+func Dump(w io.Writer, u *url.URL, cols int) (err error) {
+	jar, _ := cookies.New("")
+	b := &Browser{
+		client:   newClient(jar),
+		LocCh:    make(chan string, 10),
+		StatusCh: make(chan string, 10),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	defer b.cancel()
+	b.Website = &Website{b: b}
+	b.History.Push(u, 0)
+
+	buf, ct, err := b.get(u, true)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if ct.IsPlain() {
+		_, err = io.WriteString(w, ct.Utf8(buf))
+		return
+	}
+	if !ct.IsHTML() && !ct.IsEmpty() {
+		return fmt.Errorf("unexpected %v", ct.MediaType)
+	}
+	b.Website.ContentType = ct
+	htm := ct.Utf8(buf)
+	doc, _ := pass(b, false, htm)
+	csss := cssSrcs(b, doc, nil)
+	doc, nodeMap := pass(b, false, htm, csss...)
+	body := grep(doc, "body")
+	if body == nil {
+		return fmt.Errorf("html has no body")
+	}
+	nt := nodes.NewNodeTree(body, style.Map{}, nodeMap, &nodes.Node{})
+	hideElements(nt, u.Hostname())
+	_, err = io.WriteString(w, dumpText(nt, cols))
+	return
+}
+
+// dumpText of the node tree, similar to the layout done by NodeToBox
+func dumpText(nt *nodes.Node, cols int) string {
+	d := &dumper{cols: cols}
+	d.walk(nt)
+	d.flush()
+	return strings.TrimRight(d.buf.String(), "\n") + "\n"
+}
+
+type dumper struct {
+	cols   int
+	indent int
+	buf    strings.Builder
+	line   string
+	w      int
+	nls    int
+}
+
+func (d *dumper) word(s string) {
+	l := utf8.RuneCountInString(s)
+	if d.line != "" && d.w+1+l > d.cols {
+		d.flush()
+	}
+	if d.line == "" {
+		d.line = strings.Repeat(" ", d.indent) + s
+		d.w = d.indent + l
+	} else {
+		d.line += " " + s
+		d.w += 1 + l
+	}
+}
+
+// flush the current line, if any
+func (d *dumper) flush() {
+	if d.line == "" {
+		return
+	}
+	d.buf.WriteString(d.line + "\n")
+	d.line = ""
+	d.w = 0
+	d.nls = 1
+}
+
+// br forces a line break and allows empty lines
+func (d *dumper) br() {
+	if d.line != "" {
+		d.flush()
+	} else {
+		d.buf.WriteString("\n")
+		d.nls++
+	}
+}
+
+// para ensures an empty line, except at the beginning
+func (d *dumper) para() {
+	d.flush()
+	if d.buf.Len() > 0 && d.nls < 2 {
+		d.buf.WriteString("\n")
+		d.nls = 2
+	}
+}
+
+func (d *dumper) pre(t string) {
+	for _, l := range strings.Split(strings.Trim(t, "\n"), "\n") {
+		d.buf.WriteString(strings.Repeat(" ", d.indent) + l + "\n")
+	}
+	d.nls = 1
+}
+
+func (d *dumper) children(n *nodes.Node) {
+	for _, c := range n.Children {
+		d.walk(c)
+	}
+}
+
+func (d *dumper) li(n *nodes.Node, marker string) {
+	d.flush()
+	if marker != "" {
+		d.word(marker)
+	}
+	d.indent += 2
+	d.children(n)
+	d.indent -= 2
+	d.flush()
+}
+
+func (d *dumper) walk(n *nodes.Node) {
+	if n.IsDisplayNone() {
+		return
+	}
+	if n.Type() == html.TextNode {
+		for _, w := range strings.Fields(n.Text) {
+			d.word(w)
+		}
+		return
+	}
+	if n.Type() != html.ElementNode {
+		d.children(n)
+		return
+	}
+	if n.Attr("aria-hidden") == "true" || n.HasAttr("hidden") {
+		return
+	}
+
+	switch n.Data() {
+	case "style", "script", "template", "head", "title":
+		return
+	case "noscript":
+		if !EnableNoScriptTag {
+			return
+		}
+	case "br":
+		d.br()
+		return
+	case "img":
+		if alt := strings.TrimSpace(n.Attr("alt")); alt != "" {
+			d.word("[" + alt + "]")
+		}
+		return
+	case "input":
+		switch t := n.Attr("type"); t {
+		case "hidden":
+		case "submit", "button", "reset":
+			v := n.Attr("value")
+			if v == "" {
+				v = "Submit"
+			}
+			d.word("[" + v + "]")
+		default:
+			d.word("[" + n.Attr("value") + "]")
+		}
+		return
+	case "pre":
+		d.para()
+		d.pre(n.ContentString(true))
+		d.para()
+		return
+	case "ul", "ol":
+		d.para()
+		i := 0
+		for _, c := range n.Children {
+			if c.Data() != "li" {
+				d.walk(c)
+				continue
+			}
+			if c.IsDisplayNone() {
+				continue
+			}
+			i++
+			var marker string
+			if n.Data() == "ol" {
+				marker = fmt.Sprintf("%d.", i)
+			} else if n.Css("list-style") != "none" && c.Css("list-style-type") != "none" {
+				marker = "•"
+			}
+			d.li(c, marker)
+		}
+		d.para()
+		return
+	case "li":
+		d.li(n, "•")
+		return
+	case "tr":
+		d.flush()
+		i := 0
+		for _, c := range n.Children {
+			if c.Data() == "td" || c.Data() == "th" {
+				if i > 0 {
+					d.word("|")
+				}
+				i++
+			}
+			d.walk(c)
+		}
+		d.flush()
+		return
+	case "td", "th":
+		d.children(n)
+		return
+	}
+
+	switch n.Data() {
+	case "p", "h1", "h2", "h3", "h4", "h5", "h6", "table", "blockquote", "dl", "form":
+		d.para()
+		d.children(n)
+		d.para()
+	default:
+		if n.IsInline() || n.Css("display") == "inline" {
+			d.children(n)
+		} else {
+			d.flush()
+			d.children(n)
+			d.flush()
+		}
+	}
+}
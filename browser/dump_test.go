@@ -0,0 +1,101 @@
+package browser
+
+import (
+	"bytes"
+	"github.com/psilva261/mycel/browser/block"
+	"github.com/psilva261/mycel/browser/bookmarks"
+	"github.com/psilva261/mycel/nodes"
+	"github.com/psilva261/mycel/style"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func dumpTree(t *testing.T, htm string) *nodes.Node {
+	doc, err := html.Parse(strings.NewReader(htm))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	nm, err := style.FetchNodeMap(doc, style.AddOnCSS)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return nodes.NewNodeTree(grep(doc, "body"), style.Map{}, nm, &nodes.Node{})
+}
+
+func TestDumpText(t *testing.T) {
+	htm := `<body>
+		<h1>Title</h1>
+		<p>Some <b>bold</b> text that is long enough to be wrapped</p>
+		<div hidden>invisible</div>
+		<ul><li>one</li><li>two</li></ul>
+		<ol><li>first</li></ol>
+		<table><tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d</td></tr></table>
+		<pre>x  y
+  z</pre>
+	</body>`
+	exp := `Title
+
+Some bold text that is long
+enough to be wrapped
+
+• one
+• two
+
+1. first
+
+a | b
+c | d
+
+x  y
+  z
+`
+	res := dumpText(dumpTree(t, htm), 30)
+	if res != exp {
+		t.Fatalf("%q", res)
+	}
+}
+
+func TestDumpTextIndent(t *testing.T) {
+	htm := `<body><ul><li>item with a longer text<ul><li>sub</li></ul></li></ul></body>`
+	exp := `• item with a
+  longer text
+
+  • sub
+`
+	res := dumpText(dumpTree(t, htm), 14)
+	if res != exp {
+		t.Fatalf("%q", res)
+	}
+}
+
+func TestHideElements(t *testing.T) {
+	htm := `<body><p>text</p><div class="ad-box">ad</div><div id="sponsor-1">sponsored</div></body>`
+	nt := dumpTree(t, htm)
+	l := block.New()
+	if err := l.Load(strings.NewReader("##.ad-box\nexample.com##div[id^=\"sponsor\"]")); err != nil {
+		t.Fatalf(err.Error())
+	}
+	old := selectors
+	t.Cleanup(func() { selectors = old })
+	selectors = l.Selectors
+	hideElements(nt, "www.example.com")
+	if res := dumpText(nt, 80); res != "text\n" {
+		t.Fatalf("%q", res)
+	}
+}
+
+func TestDumpBookmarks(t *testing.T) {
+	if err := bookmarks.Add("https://9p.io/plan9/", "Plan 9"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	u, _ := url.Parse(bookmarks.URL)
+	var buf bytes.Buffer
+	if err := Dump(&buf, u, 80); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !strings.Contains(buf.String(), "Plan 9") {
+		t.Fatalf("%q", buf.String())
+	}
+}
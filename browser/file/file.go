@@ -0,0 +1,112 @@
+// Package file serves file:// URLs from the local filesystem.
+// Directories are shown as generated HTML index.
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/psilva261/mycel"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Transport for file:// URLs to register with http.Transport.RegisterProtocol
+type Transport struct{}
+
+func (Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return nil, fmt.Errorf("method %v not allowed for %v", req.Method, req.URL)
+	}
+	if h := req.URL.Host; h != "" && h != "localhost" {
+		return nil, fmt.Errorf("remote file %v", req.URL)
+	}
+	p := req.URL.Path
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return response(req, http.StatusNotFound, "text/plain; charset=utf-8", []byte(err.Error())), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+	if fi.IsDir() {
+		if !strings.HasSuffix(p, "/") {
+			// relative links need the trailing slash
+			resp = response(req, http.StatusMovedPermanently, "", nil)
+			u := *req.URL
+			u.Path += "/"
+			resp.Header.Set("Location", u.String())
+			return resp, nil
+		}
+		buf, err := index(p)
+		if err != nil {
+			return nil, fmt.Errorf("index: %w", err)
+		}
+		return response(req, http.StatusOK, "text/html; charset=utf-8", buf), nil
+	}
+	buf, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	ct := http.DetectContentType(buf)
+	if c, err := mycel.NewContentType("", req.URL); err == nil && !c.IsEmpty() {
+		ct = mime.FormatMediaType(c.MediaType, c.Params)
+	}
+	return response(req, http.StatusOK, ct, buf), nil
+}
+
+func response(req *http.Request, code int, ct string, buf []byte) *http.Response {
+	h := make(http.Header)
+	if ct != "" {
+		h.Set("Content-Type", ct)
+	}
+	if req.Method == "HEAD" {
+		buf = nil
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(buf)),
+		ContentLength: int64(len(buf)),
+		Request:       req,
+	}
+}
+
+// index of the directory d with directories first
+func index(d string) ([]byte, error) {
+	des, err := os.ReadDir(d)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(des, func(i, j int) bool {
+		return des[i].IsDir() && !des[j].IsDir()
+	})
+	var buf bytes.Buffer
+	t := html.EscapeString("Index of " + d)
+	fmt.Fprintf(&buf, "<!DOCTYPE html>\n<html><head><title>%v</title></head><body>\n<h1>%v</h1>\n<ul>\n", t, t)
+	if d != "/" {
+		buf.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, de := range des {
+		n := de.Name()
+		if de.IsDir() {
+			n += "/"
+		}
+		u := url.URL{Path: n}
+		if strings.Contains(path.Base(n), ":") {
+			// not to be taken as scheme
+			u.Path = "./" + n
+		}
+		fmt.Fprintf(&buf, "<li><a href=\"%v\">%v</a></li>\n", html.EscapeString(u.String()), html.EscapeString(n))
+	}
+	buf.WriteString("</ul>\n</body></html>\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package file
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func client() *http.Client {
+	tr := &http.Transport{}
+	tr.RegisterProtocol("file", Transport{})
+	return &http.Client{Transport: tr}
+}
+
+func TestGet(t *testing.T) {
+	d := t.TempDir()
+	if err := os.WriteFile(d+"/index.html", []byte("<p>hello</p>"), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := os.WriteFile(d+"/style.css", []byte("p { color: red; }"), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for fn, exp := range map[string]string{
+		"index.html": "text/html",
+		"style.css":  "text/css",
+	} {
+		resp, err := client().Get("file://" + d + "/" + fn)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		buf, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if resp.StatusCode != http.StatusOK || len(buf) == 0 {
+			t.Fatalf("%v: %v %q", fn, resp.Status, buf)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != exp {
+			t.Fatalf("%v: %v", fn, ct)
+		}
+	}
+	resp, err := client().Get("file://" + d + "/missing.html")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("%v", resp.Status)
+	}
+	if _, err := client().Get("file://example.com" + d + "/index.html"); err == nil {
+		t.Fatalf("remote host accepted")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	d := t.TempDir()
+	if err := os.Mkdir(d+"/sub", 0700); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, fn := range []string{"a.txt", "b&c.html", "x:y"} {
+		if err := os.WriteFile(d+"/"+fn, nil, 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	resp, err := client().Get("file://" + d)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Request.URL.Path != d+"/" {
+		t.Fatalf("not redirected: %v", resp.Request.URL)
+	}
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	h := string(buf)
+	for _, s := range []string{
+		`<a href="../">../</a>`,
+		`<a href="sub/">sub/</a>`,
+		`<a href="a.txt">a.txt</a>`,
+		`<a href="b&amp;c.html">b&amp;c.html</a>`,
+		`<a href="./x:y">x:y</a>`,
+	} {
+		if !strings.Contains(h, s) {
+			t.Fatalf("%v not in %v", s, h)
+		}
+	}
+	if strings.Index(h, "sub/") > strings.Index(h, "a.txt") {
+		t.Fatalf("directories not first: %v", h)
+	}
+}
@@ -0,0 +1,119 @@
+package browser
+
+import (
+	"github.com/mjl-/duit"
+	"github.com/psilva261/mycel/browser/duitx"
+	"image"
+	"sort"
+	"strings"
+)
+
+// Find highlights all occurrences of q in the text of the page
+// and shows the first one. It returns the number of matches.
+func (b *Browser) Find(q string) (n int) {
+	b.clearFind()
+	q = strings.ToLower(strings.Join(strings.Fields(q), " "))
+	if q == "" || b.scroller == nil {
+		b.redrawFind()
+		return 0
+	}
+	var (
+		ls   []*duitx.Label
+		offs []int
+		text strings.Builder
+	)
+	TraverseTree(b.scroller, func(ui duit.UI) {
+		if l, ok := ui.(*duitx.Label); ok && l != nil {
+			ls = append(ls, l)
+			offs = append(offs, text.Len())
+			text.WriteString(strings.ToLower(l.Text))
+		}
+	})
+	for _, m := range matches(text.String(), offs, q) {
+		b.found = append(b.found, ls[m[0]:m[1]])
+		for _, l := range ls[m[0]:m[1]] {
+			l.Found = true
+		}
+	}
+	b.foundI = 0
+	b.showFound()
+	return len(b.found)
+}
+
+// FindNext match, or the previous one if delta is negative. i is
+// the index of the current match and n the number of matches.
+func (b *Browser) FindNext(delta int) (i, n int) {
+	n = len(b.found)
+	if n == 0 {
+		return
+	}
+	b.foundI = ((b.foundI+delta)%n + n) % n
+	b.showFound()
+	return b.foundI, n
+}
+
+// ClearFind removes all highlights
+func (b *Browser) ClearFind() {
+	b.clearFind()
+	b.redrawFind()
+}
+
+func (b *Browser) clearFind() {
+	for _, m := range b.found {
+		for _, l := range m {
+			l.Found = false
+			l.Current = false
+		}
+	}
+	b.found = nil
+	b.foundI = 0
+}
+
+// showFound marks the current match and scrolls to it
+func (b *Browser) showFound() {
+	if len(b.found) == 0 {
+		b.redrawFind()
+		return
+	}
+	var r image.Rectangle
+	for i, m := range b.found {
+		for _, l := range m {
+			l.Current = i == b.foundI
+			if l.Current {
+				r = r.Union(l.Rect())
+			}
+		}
+	}
+	if b.scroller != nil && dui != nil {
+		b.scroller.Show(dui, r)
+	}
+	b.redrawFind()
+}
+
+func (b *Browser) redrawFind() {
+	if b.scroller == nil || dui == nil {
+		return
+	}
+	b.scroller.Redraw()
+	dui.MarkDraw(b.scroller)
+	dui.Render()
+}
+
+// matches of q in text where offs are the start offsets of the labels
+// the text is composed of. The results are label index ranges [from, to).
+func matches(text string, offs []int, q string) (ms [][2]int) {
+	if q == "" {
+		return
+	}
+	for p := 0; ; {
+		i := strings.Index(text[p:], q)
+		if i < 0 {
+			return
+		}
+		p += i
+		from := sort.SearchInts(offs, p+1) - 1
+		to := sort.SearchInts(offs, p+len(q))
+		ms = append(ms, [2]int{from, to})
+		p += len(q)
+	}
+}
@@ -0,0 +1,30 @@
+package browser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	words := []string{"foo ", "bar ", "baz ", "foo ", "bar "}
+	offs := make([]int, len(words))
+	var text strings.Builder
+	for i, w := range words {
+		offs[i] = text.Len()
+		text.WriteString(w)
+	}
+	tests := map[string]string{
+		"foo":     "[[0 1] [3 4]]",
+		"oo ba":   "[[0 2] [3 5]]",
+		"bar baz": "[[1 3]]",
+		"r":       "[[1 2] [4 5]]",
+		"qux":     "[]",
+	}
+	for q, exp := range tests {
+		ms := matches(text.String(), offs, q)
+		if res := fmt.Sprintf("%v", ms); res != exp {
+			t.Errorf("%v: %v", q, res)
+		}
+	}
+}
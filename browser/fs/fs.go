@@ -2,16 +2,23 @@ package fs
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	go9pfs "github.com/knusbaum/go9p/fs"
 	"github.com/knusbaum/go9p/proto"
 	"github.com/psilva261/mycel"
+	"github.com/psilva261/mycel/browser/block"
+	"github.com/psilva261/mycel/browser/bookmarks"
+	"github.com/psilva261/mycel/browser/downloads"
 	"github.com/psilva261/mycel/logger"
 	"github.com/psilva261/mycel/nodes"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os/user"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -29,8 +36,15 @@ type FS struct {
 	cssDir  *go9pfs.StaticDir
 	jsDir   *go9pfs.StaticDir
 	rt      *Node
-	Client  *http.Client
+	client  *http.Client
 	Fetcher mycel.Fetcher
+	Cookies CookieJar
+}
+
+// CookieJar that can be listed and edited in cookies.txt format
+type CookieJar interface {
+	io.WriterTo
+	Load(io.Reader) error
 }
 
 func New() *FS {
@@ -118,12 +132,16 @@ func (fs *FS) Srv9p() {
 	lq := (*go9pfs.ListenFileListener)(q)
 	root.AddChild(fs.rt)
 	go fs.Query(lq)
-	if fs.Client != nil {
-		xhr := go9pfs.NewListenFile(fs.oFS.NewStat("xhr", fs.un, fs.gn, 0600))
-		root.AddChild(xhr)
-		lxhr := (*go9pfs.ListenFileListener)(xhr)
-		go fs.Xhr(lxhr)
+	xhr := go9pfs.NewListenFile(fs.oFS.NewStat("xhr", fs.un, fs.gn, 0600))
+	root.AddChild(xhr)
+	lxhr := (*go9pfs.ListenFileListener)(xhr)
+	go fs.Xhr(lxhr)
+	if fs.Cookies != nil {
+		root.AddChild(fs.cookies())
 	}
+	root.AddChild(fs.bookmarks())
+	root.AddChild(fs.downloads())
+	root.AddChild(fs.ctl())
 	fs.c.Broadcast()
 	fs.c.L.Unlock()
 
@@ -132,6 +150,117 @@ func (fs *FS) Srv9p() {
 	}
 }
 
+// cookies file listing all cookies in cookies.txt format. Written
+// lines are set when the file is closed, lines with an expiry in
+// the past delete the cookie.
+func (fs *FS) cookies() go9pfs.FSNode {
+	return fs.loadFile(
+		"cookies",
+		func() []byte {
+			var buf bytes.Buffer
+			if _, err := fs.Cookies.WriteTo(&buf); err != nil {
+				log.Errorf("cookies: %v", err)
+			}
+			return buf.Bytes()
+		},
+		fs.Cookies.Load,
+	)
+}
+
+// loadFile with the content from gen. Data written to it is passed
+// to load when the file is closed.
+func (fs *FS) loadFile(name string, gen func() []byte, load func(io.Reader) error) go9pfs.FSNode {
+	var mu sync.Mutex
+	written := make(map[uint64]*bytes.Buffer)
+	f := go9pfs.NewDynamicFile(fs.oFS.NewStat(name, fs.un, fs.gn, 0600), gen)
+	return &go9pfs.WrappedFile{
+		File: f,
+		WriteF: func(fid uint64, offset uint64, data []byte) (uint32, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if written[fid] == nil {
+				written[fid] = &bytes.Buffer{}
+			}
+			written[fid].Write(data)
+			return uint32(len(data)), nil
+		},
+		CloseF: func(fid uint64) error {
+			mu.Lock()
+			buf, ok := written[fid]
+			delete(written, fid)
+			mu.Unlock()
+			if ok {
+				if err := load(buf); err != nil {
+					log.Errorf("%v: %v", name, err)
+				}
+			}
+			return f.Close(fid)
+		},
+	}
+}
+
+// controller is implemented by Fetchers that can be driven
+// through the ctl file
+type controller interface {
+	Ctl(cmd string) error
+}
+
+// ctl file executing one command per line written to it
+func (fs *FS) ctl() go9pfs.FSNode {
+	return &go9pfs.WrappedFile{
+		File: go9pfs.NewBaseFile(fs.oFS.NewStat("ctl", fs.un, fs.gn, 0200)),
+		WriteF: func(fid uint64, offset uint64, data []byte) (uint32, error) {
+			c, ok := fs.Fetcher.(controller)
+			if !ok {
+				return 0, fmt.Errorf("not supported")
+			}
+			for _, l := range strings.Split(string(data), "\n") {
+				if strings.TrimSpace(l) == "" {
+					continue
+				}
+				if err := c.Ctl(l); err != nil {
+					return 0, err
+				}
+			}
+			return uint32(len(data)), nil
+		},
+	}
+}
+
+// downloads file listing active and finished downloads. Writing
+// "cancel id" cancels a download.
+func (fs *FS) downloads() go9pfs.FSNode {
+	return &go9pfs.WrappedFile{
+		File: go9pfs.NewDynamicFile(fs.oFS.NewStat("downloads", fs.un, fs.gn, 0600), downloads.Text),
+		WriteF: func(fid uint64, offset uint64, data []byte) (uint32, error) {
+			for _, l := range strings.Split(string(data), "\n") {
+				cmd, arg, _ := strings.Cut(strings.TrimSpace(l), " ")
+				switch cmd {
+				case "":
+					continue
+				case "cancel":
+					id, err := strconv.Atoi(arg)
+					if err != nil {
+						return 0, fmt.Errorf("cancel: %w", err)
+					}
+					if err := downloads.Cancel(id); err != nil {
+						return 0, err
+					}
+				default:
+					return 0, fmt.Errorf("unknown command %v", cmd)
+				}
+			}
+			return uint32(len(data)), nil
+		},
+	}
+}
+
+// bookmarks file, lines appended to it are added as bookmarks
+// when the file is closed
+func (fs *FS) bookmarks() go9pfs.FSNode {
+	return fs.loadFile("bookmarks", bookmarks.Text, bookmarks.Load)
+}
+
 func (fs *FS) Query(lq *go9pfs.ListenFileListener) {
 	for {
 		conn, err := lq.Accept()
@@ -169,6 +298,13 @@ func (fs *FS) query(conn net.Conn) {
 	}
 }
 
+// SetClient used for xhr requests, they are refused if c is nil
+func (fs *FS) SetClient(c *http.Client) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.client = c
+}
+
 func (fs *FS) Xhr(lxhr *go9pfs.ListenFileListener) {
 	for {
 		conn, err := lxhr.Accept()
@@ -180,6 +316,11 @@ func (fs *FS) Xhr(lxhr *go9pfs.ListenFileListener) {
 	}
 }
 
+// blocker is implemented by Fetchers with content blocking
+type blocker interface {
+	Block(*url.URL, block.Type) bool
+}
+
 func allowed(h http.Header, reqHost, origHost string) bool {
 	if reqHost == origHost {
 		return true
@@ -204,6 +345,20 @@ func (fs *FS) xhr(conn net.Conn) {
 		url.Host = fs.Fetcher.Origin().Host
 	}
 	url.Scheme = "https"
+	fs.mu.RLock()
+	client := fs.client
+	fs.mu.RUnlock()
+	if b, ok := fs.Fetcher.(blocker); client == nil || ok && b.Block(url, block.XHR) {
+		resp := &http.Response{
+			StatusCode: http.StatusForbidden,
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+		}
+		if err := resp.Write(conn); err != nil {
+			log.Errorf("write response: %v", err)
+		}
+		return
+	}
 	proxyReq, err := http.NewRequest(req.Method, url.String(), req.Body)
 	if err != nil {
 		log.Errorf("new request: %v", err)
@@ -215,7 +370,7 @@ func (fs *FS) xhr(conn net.Conn) {
 			proxyReq.Header.Add(header, value)
 		}
 	}
-	resp, err := fs.Client.Do(proxyReq)
+	resp, err := client.Do(proxyReq)
 	if err != nil {
 		log.Errorf("do request: %v", err)
 		return
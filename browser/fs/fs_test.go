@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"io"
 	"net"
+	"net/http"
 	"testing"
 )
 
@@ -19,3 +20,17 @@ func TestQuery(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestXhrWithoutClient(t *testing.T) {
+	fs := New()
+	c1, c2 := net.Pipe()
+	go fs.xhr(c2)
+	go io.WriteString(c1, "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(c1), nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("%v", resp.Status)
+	}
+}
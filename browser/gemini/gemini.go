@@ -0,0 +1,253 @@
+// Package gemini fetches gemini:// URLs. Server certificates are
+// pinned on first use and text/gemini is converted to HTML.
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"github.com/psilva261/mycel/browser/kvfile"
+	"github.com/psilva261/mycel/logger"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultPort = "1965"
+
+// hosts are saved with one host per line and the sha256 fingerprint
+// and expiry of its certificate, e.g.
+//
+// example.com:1965 3f9a...c1 2030-01-01T00:00:00Z
+var hosts = kvfile.File[pin]{
+	Format: func(p pin) string {
+		return p.fingerprint + " " + p.notAfter.UTC().Format(time.RFC3339)
+	},
+	Parse: parsePin,
+}
+
+// pin of a host's certificate
+type pin struct {
+	fingerprint string
+	notAfter    time.Time
+}
+
+// Transport for gemini:// URLs to register with http.Transport.RegisterProtocol
+type Transport struct{}
+
+func (Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if req.Method != "GET" {
+		return nil, fmt.Errorf("method %v not allowed for %v", req.Method, req.URL)
+	}
+	u := *req.URL
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), DefaultPort)
+	}
+	d := tls.Dialer{
+		Config: &tls.Config{
+			ServerName: u.Hostname(),
+			MinVersion: tls.VersionTLS12,
+			// certificates are mostly self-signed and pinned instead
+			InsecureSkipVerify: true,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				if len(cs.PeerCertificates) == 0 {
+					return fmt.Errorf("no certificate")
+				}
+				return verify(addr, cs.PeerCertificates[0], time.Now())
+			},
+		},
+	}
+	conn, err := d.DialContext(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	stop := context.AfterFunc(req.Context(), func() {
+		conn.Close()
+	})
+	b := &body{Reader: bufio.NewReader(conn), conn: conn, stop: stop}
+	if _, err = fmt.Fprintf(conn, "%v\r\n", u.String()); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("write: %w", err)
+	}
+	status, meta, err := header(b.Reader)
+	if err != nil {
+		b.Close()
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	return response(req, &u, status, meta, b)
+}
+
+type body struct {
+	*bufio.Reader
+	conn net.Conn
+	stop func() bool
+}
+
+func (b *body) Close() error {
+	b.stop()
+	return b.conn.Close()
+}
+
+// header line of the form <STATUS><SPACE><META><CR><LF>
+func header(r *bufio.Reader) (status int, meta string, err error) {
+	l, err := r.ReadSlice('\n')
+	if err != nil {
+		return
+	}
+	s := strings.TrimRight(string(l), "\r\n")
+	if len(s) < 2 || len(s) > 1029 {
+		return 0, "", fmt.Errorf("malformed header %q", s)
+	}
+	if status, err = strconv.Atoi(s[:2]); err != nil {
+		return 0, "", fmt.Errorf("status: %w", err)
+	}
+	return status, strings.TrimSpace(s[2:]), nil
+}
+
+func response(req *http.Request, u *url.URL, status int, meta string, b *body) (resp *http.Response, err error) {
+	h := make(http.Header)
+	switch status / 10 {
+	case 1:
+		b.Close()
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		return newResponse(req, http.StatusOK, h, prompt(u, meta, status == 11)), nil
+	case 2:
+		if meta == "" {
+			meta = "text/gemini; charset=utf-8"
+		}
+		mt, params, err := mime.ParseMediaType(meta)
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("media type: %w", err)
+		}
+		if mt != "text/gemini" {
+			h.Set("Content-Type", meta)
+			resp = newResponse(req, http.StatusOK, h, nil)
+			resp.Body = b
+			resp.ContentLength = -1
+			return resp, nil
+		}
+		defer b.Close()
+		buf, err := HTML(b)
+		if err != nil {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+		params["charset"] = strings.ToLower(params["charset"])
+		if params["charset"] == "" {
+			params["charset"] = "utf-8"
+		}
+		h.Set("Content-Type", mime.FormatMediaType("text/html", params))
+		return newResponse(req, http.StatusOK, h, buf), nil
+	case 3:
+		b.Close()
+		loc, err := u.Parse(meta)
+		if err != nil {
+			return nil, fmt.Errorf("redirect: %w", err)
+		}
+		code := http.StatusFound
+		if status == 31 {
+			code = http.StatusMovedPermanently
+		}
+		h.Set("Location", loc.String())
+		return newResponse(req, code, h, nil), nil
+	}
+	b.Close()
+	code := http.StatusBadRequest
+	switch {
+	case status == 51:
+		code = http.StatusNotFound
+	case status/10 == 4:
+		code = http.StatusServiceUnavailable
+	case status/10 == 6:
+		code = http.StatusForbidden
+	}
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	return newResponse(req, code, h, []byte(fmt.Sprintf("%v %v", status, meta))), nil
+}
+
+func newResponse(req *http.Request, code int, h http.Header, buf []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(buf)),
+		ContentLength: int64(len(buf)),
+		Request:       req,
+	}
+}
+
+// prompt for input that is sent to u as query
+func prompt(u *url.URL, text string, sensitive bool) []byte {
+	a := *u
+	a.RawQuery = ""
+	typ := "text"
+	if sensitive {
+		typ = "password"
+	}
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html><head><title>%v</title></head><body>
+<form method="get" action="%v">
+<p>%v</p>
+<input type="%v" name="q">
+<input type="submit" value="Send">
+</form>
+</body></html>
+`, html.EscapeString(text), html.EscapeString(a.String()), html.EscapeString(text), typ))
+}
+
+// verify cert of the server at addr against the pinned certificate.
+// New certificates are accepted on first use or when the pinned one
+// has expired.
+func verify(addr string, cert *x509.Certificate, now time.Time) (err error) {
+	sum := sha256.Sum256(cert.Raw)
+	fp := hex.EncodeToString(sum[:])
+	serr := hosts.Update(addr, func(p pin, ok bool) (pin, bool) {
+		if ok && p.fingerprint == fp {
+			return p, false
+		} else if ok && now.Before(p.notAfter) {
+			err = fmt.Errorf("certificate of %v changed", addr)
+			return p, false
+		}
+		return pin{fingerprint: fp, notAfter: cert.NotAfter}, true
+	})
+	if serr != nil {
+		log.Errorf("gemini hosts: %v", serr)
+	}
+	return
+}
+
+// SetFile loads the pinned certificates of gemini hosts from f, new
+// ones are stored there
+func SetFile(f string) error {
+	return hosts.SetFile(f)
+}
+
+func parsePin(fs []string) (p pin, err error) {
+	if len(fs) != 2 {
+		return p, fmt.Errorf("malformed line")
+	}
+	t, err := time.Parse(time.RFC3339, fs[1])
+	if err != nil {
+		return p, fmt.Errorf("expiry: %w", err)
+	}
+	return pin{fingerprint: fs[0], notAfter: t}, nil
+}
@@ -0,0 +1,155 @@
+package gemini
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTML(t *testing.T) {
+	g := "# Title\nText & more\n=> gemini://example.com/a Link A\n=>/b\n* one\n* two\n```alt\n  x < y\n```\n> quote\n## Sub\n"
+	buf, err := HTML(strings.NewReader(g))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	h := string(buf)
+	for _, s := range []string{
+		"<title>Title</title>",
+		"<h1>Title</h1>",
+		"<p>Text &amp; more</p>",
+		`<p><a href="gemini://example.com/a">Link A</a></p>`,
+		`<p><a href="/b">/b</a></p>`,
+		"<ul>\n<li>one</li>\n<li>two</li>\n</ul>",
+		"<pre>  x &lt; y\n</pre>",
+		"<blockquote>quote</blockquote>",
+		"<h2>Sub</h2>",
+	} {
+		if !strings.Contains(h, s) {
+			t.Fatalf("%v not in %v", s, h)
+		}
+	}
+}
+
+func cert(t *testing.T, notAfter time.Time) tls.Certificate {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &k.PublicKey, k)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: k}
+}
+
+func TestVerify(t *testing.T) {
+	fn := t.TempDir() + "/gemini_hosts"
+	if err := SetFile(fn); err != nil {
+		t.Fatalf("%v", err)
+	}
+	now := time.Now()
+	a, _ := x509.ParseCertificate(cert(t, now.Add(time.Hour)).Certificate[0])
+	b, _ := x509.ParseCertificate(cert(t, now.Add(time.Hour)).Certificate[0])
+	if err := verify("example.com:1965", a, now); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := verify("example.com:1965", a, now); err != nil {
+		t.Fatalf("same: %v", err)
+	}
+	if err := verify("example.com:1965", b, now); err == nil {
+		t.Fatalf("changed certificate accepted")
+	}
+	if err := verify("example.com:1965", b, now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("after expiry: %v", err)
+	}
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !strings.HasPrefix(string(buf), "example.com:1965 ") {
+		t.Fatalf("%q", buf)
+	}
+	if err := SetFile(fn); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := verify("example.com:1965", b, now); err != nil {
+		t.Fatalf("reloaded: %v", err)
+	}
+}
+
+// serve responses keyed by request uri
+func serve(t *testing.T, res map[string]string) (addr string) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert(t, time.Now().Add(time.Hour))},
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r, _ := bufio.NewReader(c).ReadString('\n')
+			if u, err := url.Parse(strings.TrimSpace(r)); err == nil {
+				io.WriteString(c, res[u.RequestURI()])
+			}
+			c.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestTransport(t *testing.T) {
+	SetFile("")
+	addr := serve(t, map[string]string{
+		"/":            "20 text/gemini\r\n# Hello\n=> /input\n",
+		"/input":       "11 Password\r\n",
+		"/input?a%20b": "30 /\r\n",
+		"/file.bin":    "20 application/octet-stream\r\nxyz",
+		"/missing":     "51 Not found\r\n",
+	})
+	tr := &http.Transport{}
+	tr.RegisterProtocol("gemini", Transport{})
+	c := &http.Client{Transport: tr}
+	for path, exp := range map[string]string{
+		"/":            "<h1>Hello</h1>",
+		"/input":       `<input type="password" name="q">`,
+		"/input?a%20b": "<h1>Hello</h1>",
+		"/file.bin":    "xyz",
+		"/missing":     "51 Not found",
+	} {
+		resp, err := c.Get("gemini://" + addr + path)
+		if err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		buf, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		if !strings.Contains(string(buf), exp) {
+			t.Fatalf("%v: %q", path, buf)
+		}
+	}
+}
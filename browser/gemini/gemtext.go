@@ -0,0 +1,96 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// HTML of the text/gemini document in r
+func HTML(r io.Reader) (buf []byte, err error) {
+	var b bytes.Buffer
+	var title string
+	var pre, list bool
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		l := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.HasPrefix(l, "```") {
+			if pre {
+				b.WriteString("</pre>\n")
+			} else {
+				if list {
+					b.WriteString("</ul>\n")
+					list = false
+				}
+				b.WriteString("<pre>")
+			}
+			pre = !pre
+			continue
+		}
+		if pre {
+			b.WriteString(html.EscapeString(l) + "\n")
+			continue
+		}
+		if strings.HasPrefix(l, "* ") {
+			if !list {
+				b.WriteString("<ul>\n")
+				list = true
+			}
+			fmt.Fprintf(&b, "<li>%v</li>\n", html.EscapeString(l[2:]))
+			continue
+		} else if list {
+			b.WriteString("</ul>\n")
+			list = false
+		}
+		switch {
+		case strings.HasPrefix(l, "=>"):
+			href, label := link(l[2:])
+			fmt.Fprintf(&b, "<p><a href=\"%v\">%v</a></p>\n", html.EscapeString(href), html.EscapeString(label))
+		case strings.HasPrefix(l, "###"):
+			fmt.Fprintf(&b, "<h3>%v</h3>\n", html.EscapeString(strings.TrimSpace(l[3:])))
+		case strings.HasPrefix(l, "##"):
+			fmt.Fprintf(&b, "<h2>%v</h2>\n", html.EscapeString(strings.TrimSpace(l[2:])))
+		case strings.HasPrefix(l, "#"):
+			h := strings.TrimSpace(l[1:])
+			if title == "" {
+				title = h
+			}
+			fmt.Fprintf(&b, "<h1>%v</h1>\n", html.EscapeString(h))
+		case strings.HasPrefix(l, ">"):
+			fmt.Fprintf(&b, "<blockquote>%v</blockquote>\n", html.EscapeString(strings.TrimSpace(l[1:])))
+		case strings.TrimSpace(l) == "":
+			b.WriteString("<br>\n")
+		default:
+			fmt.Fprintf(&b, "<p>%v</p>\n", html.EscapeString(l))
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	if pre {
+		b.WriteString("</pre>\n")
+	}
+	if list {
+		b.WriteString("</ul>\n")
+	}
+	head := fmt.Sprintf("<!DOCTYPE html>\n<html><head><title>%v</title></head><body>\n", html.EscapeString(title))
+	return append([]byte(head), append(b.Bytes(), "</body></html>\n"...)...), nil
+}
+
+// link of a => line, the url is used as label if there is none
+func link(l string) (href, label string) {
+	fs := strings.Fields(l)
+	if len(fs) == 0 {
+		return
+	}
+	href = fs[0]
+	label = strings.TrimSpace(strings.TrimSpace(l)[len(href):])
+	if label == "" {
+		label = href
+	}
+	return
+}
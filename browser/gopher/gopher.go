@@ -0,0 +1,218 @@
+// Package gopher fetches gopher:// URLs. Menus and search results are
+// converted to HTML.
+package gopher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const DefaultPort = "70"
+
+// Transport for gopher:// URLs to register with http.Transport.RegisterProtocol
+type Transport struct{}
+
+func (Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if req.Method != "GET" {
+		return nil, fmt.Errorf("method %v not allowed for %v", req.Method, req.URL)
+	}
+	typ, sel, query, err := parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+	if typ == '7' && query == "" {
+		return newResponse(req, "text/html; charset=utf-8", search(req.URL)), nil
+	}
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), DefaultPort)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	stop := context.AfterFunc(req.Context(), func() {
+		conn.Close()
+	})
+	b := &body{Reader: bufio.NewReader(conn), conn: conn, stop: stop}
+	if query != "" {
+		sel += "\t" + query
+	}
+	if _, err = fmt.Fprintf(conn, "%v\r\n", sel); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("write: %w", err)
+	}
+	ct := ""
+	switch typ {
+	case '1', '7':
+		defer b.Close()
+		buf, err := HTML(b, req.URL.Host)
+		if err != nil {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+		return newResponse(req, "text/html; charset=utf-8", buf), nil
+	case '0':
+		ct = "text/plain; charset=utf-8"
+	case 'h':
+		ct = "text/html; charset=utf-8"
+	case 'g':
+		ct = "image/gif"
+	case '4', '5', '6', '9':
+		ct = "application/octet-stream"
+	default:
+		p, _ := b.Peek(512)
+		ct = http.DetectContentType(p)
+	}
+	resp = newResponse(req, ct, nil)
+	resp.Body = b
+	resp.ContentLength = -1
+	return resp, nil
+}
+
+type body struct {
+	*bufio.Reader
+	conn net.Conn
+	stop func() bool
+}
+
+func (b *body) Close() error {
+	b.stop()
+	return b.conn.Close()
+}
+
+// parse item type, selector and search query of u like
+// gopher://example.com/7/search%09query. Menus are the default
+// type and queries can also be given as url query.
+func parse(u *url.URL) (typ byte, sel, query string, err error) {
+	p := strings.TrimPrefix(u.Path, "/")
+	if p == "" {
+		return '1', "", "", nil
+	}
+	typ, sel = p[0], p[1:]
+	if i := strings.Index(sel, "\t"); i >= 0 {
+		sel, query = sel[:i], sel[i+1:]
+	}
+	if u.RawQuery != "" {
+		q, err := url.PathUnescape(u.RawQuery)
+		if err != nil {
+			return 0, "", "", fmt.Errorf("query: %w", err)
+		}
+		if typ == '7' {
+			query = q
+		} else {
+			sel += "?" + q
+		}
+	}
+	return
+}
+
+func newResponse(req *http.Request, ct string, buf []byte) *http.Response {
+	h := make(http.Header)
+	h.Set("Content-Type", ct)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(buf)),
+		ContentLength: int64(len(buf)),
+		Request:       req,
+	}
+}
+
+// search form sending its input as query to u
+func search(u *url.URL) []byte {
+	a := *u
+	a.RawQuery = ""
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html><head><title>Search</title></head><body>
+<form method="get" action="%v">
+<p>Search</p>
+<input type="text" name="q">
+<input type="submit" value="Search">
+</form>
+</body></html>
+`, html.EscapeString(a.String())))
+}
+
+// HTML of the gopher menu in r. Info lines are kept preformatted,
+// items become links.
+func HTML(r io.Reader, title string) (buf []byte, err error) {
+	var b bytes.Buffer
+	var pre bool
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><title>%v</title></head><body>\n", html.EscapeString(title))
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		l := strings.TrimSuffix(sc.Text(), "\r")
+		if l == "." {
+			break
+		}
+		if l == "" {
+			continue
+		}
+		fs := strings.Split(l[1:], "\t")
+		text := fs[0]
+		if l[0] == 'i' || l[0] == '3' {
+			if !pre {
+				b.WriteString("<pre>")
+				pre = true
+			}
+			b.WriteString(html.EscapeString(text) + "\n")
+			continue
+		}
+		if pre {
+			b.WriteString("</pre>\n")
+			pre = false
+		}
+		href := link(l[0], fs)
+		if href == "" {
+			fmt.Fprintf(&b, "<div>%v</div>\n", html.EscapeString(text))
+		} else {
+			fmt.Fprintf(&b, "<div><a href=\"%v\">%v</a></div>\n", html.EscapeString(href), html.EscapeString(text))
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	if pre {
+		b.WriteString("</pre>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.Bytes(), nil
+}
+
+// link of the menu item with fields display, selector, host and port
+func link(typ byte, fs []string) string {
+	if len(fs) < 3 {
+		return ""
+	}
+	sel, host := fs[1], fs[2]
+	switch typ {
+	case '2', '8', 'T', '+':
+		// cso, telnet and mirrors
+		return ""
+	case 'h':
+		if strings.HasPrefix(sel, "URL:") {
+			return sel[4:]
+		}
+	}
+	if len(fs) > 3 && fs[3] != DefaultPort && fs[3] != "" {
+		host = net.JoinHostPort(host, fs[3])
+	}
+	u := url.URL{
+		Scheme: "gopher",
+		Host:   host,
+		Path:   "/" + string(typ) + sel,
+	}
+	return u.String()
+}
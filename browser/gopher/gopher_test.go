@@ -0,0 +1,93 @@
+package gopher
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	m := "iWelcome <home>\tfake\t(NULL)\t0\r\n" +
+		"i  ascii art\tfake\t(NULL)\t0\r\n" +
+		"1Docs\t/docs\texample.com\t70\r\n" +
+		"0About me\t/about.txt\texample.com\t7070\r\n" +
+		"7Search\t/search\texample.com\t70\r\n" +
+		"hWeb\tURL:https://example.com/\texample.com\t70\r\n" +
+		"8Telnet\t\texample.com\t23\r\n" +
+		".\r\n" +
+		"iafter end\tfake\t(NULL)\t0\r\n"
+	buf, err := HTML(strings.NewReader(m), "example.com")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	h := string(buf)
+	for _, s := range []string{
+		"<title>example.com</title>",
+		"<pre>Welcome &lt;home&gt;\n  ascii art\n</pre>",
+		`<a href="gopher://example.com/1/docs">Docs</a>`,
+		`<a href="gopher://example.com:7070/0/about.txt">About me</a>`,
+		`<a href="gopher://example.com/7/search">Search</a>`,
+		`<a href="https://example.com/">Web</a>`,
+		"<div>Telnet</div>",
+	} {
+		if !strings.Contains(h, s) {
+			t.Fatalf("%v not in %v", s, h)
+		}
+	}
+	if strings.Contains(h, "after end") {
+		t.Fatalf("%v", h)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer l.Close()
+	res := map[string]string{
+		"":             "0Text\t/t\texample.com\t70\r\n.\r\n",
+		"/t":           "plain text",
+		"/s\tmy query": "iresult\t\t\t\r\n.\r\n",
+		"/cgi?a=1":     "query in selector",
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r, _ := bufio.NewReader(c).ReadString('\n')
+			io.WriteString(c, res[strings.TrimRight(r, "\r\n")])
+			c.Close()
+		}
+	}()
+	tr := &http.Transport{}
+	tr.RegisterProtocol("gopher", Transport{})
+	c := &http.Client{Transport: tr}
+	base := "gopher://" + l.Addr().String()
+	for path, exp := range map[string]string{
+		"":                  `<a href="gopher://example.com/0/t">Text</a>`,
+		"/0/t":              "plain text",
+		"/7/s":              `<input type="text" name="q">`,
+		"/7/s%09my%20query": "<pre>result\n</pre>",
+		"/7/s?my%20query":   "<pre>result\n</pre>",
+		"/0/cgi?a=1":        "query in selector",
+	} {
+		resp, err := c.Get(base + path)
+		if err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		buf, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		if !strings.Contains(string(buf), exp) {
+			t.Fatalf("%v: %q", path, buf)
+		}
+	}
+}
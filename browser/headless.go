@@ -0,0 +1,67 @@
+package browser
+
+import (
+	"9fans.net/go/draw"
+	"fmt"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/mycel/browser/cookies"
+	"github.com/psilva261/mycel/browser/duitx"
+	"github.com/psilva261/mycel/style"
+	"image"
+	"net/url"
+)
+
+// NewHeadless Browser loading u. Like Dump it doesn't use the
+// profile: no session, cookies, settings or 9P filesystem.
+func NewHeadless(_dui *duit.DUI, u *url.URL) (b *Browser, err error) {
+	dui = _dui
+	jar, _ := cookies.New("")
+	b = newBrowser(newClient(jar), nil)
+	b.History.Push(u, 0)
+	style.SetFetcher(b)
+	if err = initDisplay(); err != nil {
+		return nil, err
+	}
+	b.LoadUrl(u)
+	return
+}
+
+// maxSnapshotHeight limits the offscreen image in lowDPI pixels
+const maxSnapshotHeight = 16384
+
+// Snapshot lays out the whole website offscreen with the given
+// width in lowDPI pixels and returns the drawn result. Unlike the
+// window, the image is not cut off at the scroll viewport.
+func (b *Browser) Snapshot(width int) (i *image.RGBA, err error) {
+	if dui == nil {
+		return nil, fmt.Errorf("no dui")
+	}
+	ui := b.Website.UI
+	if s, ok := ui.(*duitx.Scroll); ok {
+		ui = s.Kid.UI
+	}
+	kid := &duit.Kid{UI: ui}
+	w := dui.Scale(width)
+	ui.Layout(dui, kid, image.Pt(w, dui.Scale(style.WindowHeight)), true)
+
+	h := kid.R.Dy()
+	if h <= 0 {
+		return nil, fmt.Errorf("empty layout")
+	}
+	if max := dui.Scale(maxSnapshotHeight); h > max {
+		h = max
+	}
+	r := image.Rect(0, 0, w, h)
+	img, err := dui.Display.AllocImage(r, draw.ABGR32, false, draw.White)
+	if err != nil {
+		return nil, fmt.Errorf("alloc img: %w", err)
+	}
+	defer img.Free()
+	ui.Draw(dui, kid, img, image.ZP, draw.Mouse{}, true)
+
+	i = image.NewRGBA(r)
+	if _, err := img.Unload(r, i.Pix); err != nil {
+		return nil, fmt.Errorf("unload: %w", err)
+	}
+	return
+}
@@ -1,49 +1,145 @@
 package history
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
+// History is a back/forward stack where i points
+// to the current item.
 type History struct {
 	items []Item
+	i     int
+}
+
+func (h History) Len() int {
+	return len(h.items)
 }
 
 func (h History) URL() *url.URL {
-	return h.items[len(h.items)-1].URL
+	return h.items[h.i].URL
 }
 
 func (h *History) Push(u *url.URL, oldScroll int) {
 	if len(h.items) > 0 {
-		if h.items[len(h.items)-1].URL.String() == u.String() {
+		if h.items[h.i].URL.String() == u.String() {
 			return
 		}
 		h.setScroll(oldScroll)
+		h.items = h.items[:h.i+1]
 	}
 	it := Item{u, 0}
 	h.items = append(h.items, it)
+	h.i = len(h.items) - 1
 }
 
-func (h *History) Back() {
-	if len(h.items) > 1 {
-		h.items = h.items[:len(h.items)-1]
+func (h *History) Back(oldScroll int) {
+	if h.i > 0 {
+		h.setScroll(oldScroll)
+		h.i--
 	}
 }
 
+func (h *History) Forward(oldScroll int) {
+	if h.i < len(h.items)-1 {
+		h.setScroll(oldScroll)
+		h.i++
+	}
+}
+
+// Go to item i without discarding the other items
+func (h *History) Go(i int, oldScroll int) {
+	if 0 <= i && i < len(h.items) {
+		h.setScroll(oldScroll)
+		h.i = i
+	}
+}
+
+func (h *History) CanBack() bool {
+	return h.i > 0
+}
+
+func (h *History) CanForward() bool {
+	return h.i < len(h.items)-1
+}
+
+// Items and the index of the current one
+func (h *History) Items() (its []Item, i int) {
+	return append([]Item{}, h.items...), h.i
+}
+
 func (h *History) String() string {
 	addrs := make([]string, len(h.items))
 	for i, it := range h.items {
 		addrs[i] = it.URL.String()
+		if i == h.i {
+			addrs[i] = "*" + addrs[i]
+		}
 	}
 	return strings.Join(addrs, ", ")
 }
 
 func (h *History) Scroll() int {
-	return h.items[len(h.items)-1].Scroll
+	return h.items[h.i].Scroll
 }
 
 func (h *History) setScroll(s int) {
-	h.items[len(h.items)-1].Scroll = s
+	h.items[h.i].Scroll = s
+}
+
+// Save the history with one item per line, the first line
+// is the index of the current item:
+//
+// 1
+// https://example.com 0
+// https://example.com/a 230
+func (h *History) Save(w io.Writer) (err error) {
+	if _, err = fmt.Fprintf(w, "%d\n", h.i); err != nil {
+		return
+	}
+	for _, it := range h.items {
+		if _, err = fmt.Fprintf(w, "%v %d\n", it.URL, it.Scroll); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Load history in the format written by Save
+func Load(r io.Reader) (h History, err error) {
+	sc := bufio.NewScanner(r)
+	if !sc.Scan() {
+		return h, fmt.Errorf("empty")
+	}
+	if h.i, err = strconv.Atoi(strings.TrimSpace(sc.Text())); err != nil {
+		return h, fmt.Errorf("parse index: %w", err)
+	}
+	for sc.Scan() {
+		l := strings.Fields(sc.Text())
+		if len(l) != 2 {
+			continue
+		}
+		u, err := url.Parse(l[0])
+		if err != nil {
+			return h, fmt.Errorf("parse url: %w", err)
+		}
+		s, err := strconv.Atoi(l[1])
+		if err != nil {
+			return h, fmt.Errorf("parse scroll: %w", err)
+		}
+		h.items = append(h.items, Item{u, s})
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	if h.i < 0 || h.i >= len(h.items) {
+		return h, fmt.Errorf("index %v out of range", h.i)
+	}
+	return
 }
 
 type Item struct {
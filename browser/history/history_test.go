@@ -2,6 +2,7 @@ package history
 
 import (
 	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +26,48 @@ func TestNoDup(t *testing.T) {
 		t.Error()
 	}
 }
+
+func push(t *testing.T, h *History, uris ...string) {
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		h.Push(u, 0)
+	}
+}
+
+func TestBackForward(t *testing.T) {
+	h := History{}
+	push(t, &h, "https://example.com", "https://example.com/a", "https://example.com/b")
+	h.Back(10)
+	h.Back(20)
+	if h.URL().String() != "https://example.com" || h.CanBack() || !h.CanForward() {
+		t.Fatalf("%v", h.String())
+	}
+	h.Forward(0)
+	if h.URL().String() != "https://example.com/a" || h.Scroll() != 20 {
+		t.Fatalf("%v", h.String())
+	}
+	push(t, &h, "https://example.com/c")
+	if len(h.items) != 3 || h.CanForward() {
+		t.Fatalf("%v", h.String())
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	h := History{}
+	push(t, &h, "https://example.com", "https://example.com/a")
+	h.Back(30)
+	var b strings.Builder
+	if err := h.Save(&b); err != nil {
+		t.Fatalf("%v", err)
+	}
+	hh, err := Load(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if hh.String() != h.String() || hh.items[1].Scroll != 30 {
+		t.Fatalf("%v", hh.String())
+	}
+}
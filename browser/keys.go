@@ -0,0 +1,247 @@
+package browser
+
+import (
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/mycel/browser/duitx"
+	"github.com/psilva261/mycel/logger"
+	"image"
+	"strings"
+)
+
+const (
+	// BackTabKey moves the focus backwards. Draw devices don't
+	// distinguish Shift-Tab from Tab.
+	BackTabKey = draw.KeyCmd + '\t'
+
+	// HintKey shows link hints
+	HintKey = 'f'
+
+	hintChars = "asdfghjkl"
+)
+
+var focusBg, hintBg *draw.Image
+
+// focusable elements in document order
+func (b *Browser) focusable() (els []*Element) {
+	if b.scroller == nil {
+		return
+	}
+	TraverseTree(b.scroller, func(ui duit.UI) {
+		el, ok := ui.(*Element)
+		if !ok || el == nil || el.n == nil {
+			return
+		}
+		if len(els) > 0 && els[len(els)-1].n == el.n {
+			return
+		}
+		switch el.n.Data() {
+		case "a":
+			if el.link == nil {
+				return
+			}
+		case "input", "select", "button", "textarea":
+		default:
+			return
+		}
+		els = append(els, el)
+	})
+	return
+}
+
+// FocusNext focusable element, the previous one if delta is negative
+func (b *Browser) FocusNext(delta int) {
+	els := b.focusable()
+	if len(els) == 0 {
+		return
+	}
+	i := -1
+	for j, el := range els {
+		if el == b.focused {
+			i = j
+		}
+	}
+	if i < 0 && delta < 0 {
+		i = 0
+	}
+	i = ((i+delta)%len(els) + len(els)) % len(els)
+	b.focus(els[i])
+}
+
+// focus el and show it. The pointer is moved to form controls
+// so that they receive the keyboard input.
+func (b *Browser) focus(el *Element) {
+	b.focused = el
+	if b.scroller != nil {
+		b.scroller.Show(dui, el.bounds())
+	}
+	b.redrawKeys()
+	if el.link == nil {
+		dui.Focus(el.inner())
+	}
+}
+
+// activate the focused link
+func (b *Browser) activate() bool {
+	el := b.focused
+	if el == nil || el.link == nil {
+		return false
+	}
+	el.click()
+	return true
+}
+
+// inner UI of el without the box used for sizing
+func (el *Element) inner() duit.UI {
+	if bx, ok := el.UI.(*duitx.Box); ok && len(bx.Kids) == 1 {
+		return bx.Kids[0].UI
+	}
+	return el.UI
+}
+
+// bounds of el in the coordinates of the scroll content
+func (el *Element) bounds() image.Rectangle {
+	return image.Rectangle{el.orig, el.orig.Add(el.rect.Size())}
+}
+
+// showHints labels the visible links with short codes
+func (b *Browser) showHints() {
+	if b.scroller == nil {
+		return
+	}
+	vis := b.scroller.Visible()
+	var els []*Element
+	for _, el := range b.focusable() {
+		if el.link != nil && el.bounds().Overlaps(vis) {
+			els = append(els, el)
+		}
+	}
+	if len(els) == 0 {
+		return
+	}
+	codes := hintCodes(len(els))
+	b.hints = make(map[*Element]string)
+	for i, el := range els {
+		b.hints[el] = codes[i]
+	}
+	b.hintInput = ""
+	b.redrawKeys()
+}
+
+// hintKey handles k while hints are shown
+func (b *Browser) hintKey(k rune) {
+	b.hintInput += string(k)
+	var match *Element
+	prefix := false
+	for el, c := range b.hints {
+		if c == b.hintInput {
+			match = el
+		} else if strings.HasPrefix(c, b.hintInput) {
+			prefix = true
+		}
+	}
+	if match == nil && prefix {
+		return
+	}
+	b.hints = nil
+	b.redrawKeys()
+	if match != nil {
+		b.focus(match)
+		b.activate()
+	}
+}
+
+// hintCodes of equal length for n links
+func hintCodes(n int) (codes []string) {
+	l := 1
+	for m := len(hintChars); m < n; m *= len(hintChars) {
+		l++
+	}
+	for i := 0; i < n; i++ {
+		c := make([]byte, l)
+		for j, k := l-1, i; j >= 0; j-- {
+			c[j] = hintChars[k%len(hintChars)]
+			k /= len(hintChars)
+		}
+		codes = append(codes, string(c))
+	}
+	return
+}
+
+// drawKeys draws the focus ring and hint of el
+func (el *Element) drawKeys(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point) {
+	b := el.b
+	if b == nil || (b.focused != el && b.hints[el] == "") {
+		return
+	}
+	if focusBg == nil {
+		var err error
+		focusBg, err = dui.Display.AllocImage(image.Rect(0, 0, 1, 1), draw.ARGB32, true, 0x4169e1ff)
+		if err != nil {
+			log.Errorf("alloc: %v", err)
+			return
+		}
+		hintBg, err = dui.Display.AllocImage(image.Rect(0, 0, 1, 1), draw.ARGB32, true, 0xffd700ff)
+		if err != nil {
+			log.Errorf("alloc: %v", err)
+			return
+		}
+	}
+	r := image.Rectangle{orig, orig.Add(self.R.Size())}
+	if b.focused == el {
+		img.Border(r, dui.Scale(2), focusBg, image.ZP)
+	}
+	if c := b.hints[el]; c != "" {
+		f := dui.Display.Font
+		hr := image.Rectangle{r.Min, r.Min.Add(f.StringSize(c)).Add(image.Pt(dui.Scale(4), 0))}
+		img.Draw(hr, hintBg, nil, image.ZP)
+		img.String(hr.Min.Add(image.Pt(dui.Scale(2), 0)), dui.Display.Black, image.ZP, f, c)
+	}
+}
+
+func (b *Browser) redrawKeys() {
+	if b.scroller == nil || dui == nil {
+		return
+	}
+	b.scroller.Redraw()
+	dui.MarkDraw(b.scroller)
+	dui.Render()
+}
+
+// keys of the body element for focus traversal, link hints and
+// zooming, ok is false if k is not handled
+func (el *Element) keys(k rune) (ok bool) {
+	b := el.b
+	if b.hints != nil {
+		if k == draw.KeyEscape {
+			b.hints = nil
+			b.redrawKeys()
+		} else {
+			b.hintKey(k)
+		}
+		return true
+	}
+	switch k {
+	case '\t':
+		b.FocusNext(1)
+	case BackTabKey:
+		b.FocusNext(-1)
+	case '\n':
+		return b.activate()
+	case draw.KeyCmd + '+', draw.KeyCmd + '=':
+		b.Zoom(1)
+	case draw.KeyCmd + '-':
+		b.Zoom(-1)
+	case draw.KeyCmd + '0':
+		b.Zoom(0)
+	case draw.KeyEscape:
+		if b.focused == nil {
+			return false
+		}
+		b.focused = nil
+		b.redrawKeys()
+	default:
+		return false
+	}
+	return true
+}
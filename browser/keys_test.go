@@ -0,0 +1,23 @@
+package browser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHintCodes(t *testing.T) {
+	if cs := hintCodes(3); strings.Join(cs, ",") != "a,s,d" {
+		t.Fatalf("%+v", cs)
+	}
+	cs := hintCodes(10)
+	if len(cs) != 10 || cs[0] != "aa" || cs[9] != "sa" {
+		t.Fatalf("%+v", cs)
+	}
+	seen := make(map[string]bool)
+	for _, c := range hintCodes(100) {
+		if len(c) != 3 || seen[c] {
+			t.Fatalf("%v", c)
+		}
+		seen[c] = true
+	}
+}
@@ -0,0 +1,137 @@
+// Package kvfile keeps a map in a text file with one key and its
+// fields per line. Empty lines and lines starting with # are skipped.
+package kvfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// File mirrors a map of values keyed by the first field of each line.
+// The whole file is rewritten on every change.
+type File[V any] struct {
+	// Format the fields of v that follow its key
+	Format func(v V) string
+	// Parse the fields following a key
+	Parse func(fs []string) (V, error)
+	// Fold keys to lower case when loading
+	Fold bool
+
+	mu sync.RWMutex
+	m  map[string]V
+	fn string
+}
+
+// Get the value of k
+func (f *File[V]) Get(k string) (v V, ok bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	v, ok = f.m[k]
+	return
+}
+
+// Set the value of k and save the file
+func (f *File[V]) Set(k string, v V) error {
+	return f.Update(k, func(V, bool) (V, bool) {
+		return v, true
+	})
+}
+
+// Update k to the value fn returns for the current one and save the
+// file, unless fn also returns false.
+func (f *File[V]) Update(k string, fn func(v V, ok bool) (V, bool)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.m[k]
+	v, keep := fn(v, ok)
+	if !keep {
+		return nil
+	}
+	if f.m == nil {
+		f.m = make(map[string]V)
+	}
+	f.m[k] = v
+	return f.store()
+}
+
+// Delete k and save the file
+func (f *File[V]) Delete(k string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.m, k)
+	return f.store()
+}
+
+// SetFile clears the map and loads it from fn, which is created on the
+// next change. With fn empty nothing is saved.
+func (f *File[V]) SetFile(fn string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fn = fn
+	f.m = make(map[string]V)
+	if fn == "" {
+		return
+	}
+	r, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer r.Close()
+	return f.load(r)
+}
+
+func (f *File[V]) store() (err error) {
+	if f.fn == "" {
+		return
+	}
+	w, err := os.Create(f.fn)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	if err = f.save(w); err != nil {
+		w.Close()
+		return fmt.Errorf("save: %w", err)
+	}
+	return w.Close()
+}
+
+func (f *File[V]) save(w io.Writer) (err error) {
+	ks := make([]string, 0, len(f.m))
+	for k := range f.m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		if _, err = fmt.Fprintf(w, "%v %v\n", k, f.Format(f.m[k])); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (f *File[V]) load(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fs := strings.Fields(sc.Text())
+		if len(fs) == 0 || strings.HasPrefix(fs[0], "#") {
+			continue
+		}
+		v, err := f.Parse(fs[1:])
+		if err != nil {
+			return fmt.Errorf("%v: %w", fs[0], err)
+		}
+		k := fs[0]
+		if f.Fold {
+			k = strings.ToLower(k)
+		}
+		f.m[k] = v
+	}
+	return sc.Err()
+}
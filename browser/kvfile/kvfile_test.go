@@ -0,0 +1,70 @@
+package kvfile
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newFile() *File[int] {
+	return &File[int]{
+		Format: strconv.Itoa,
+		Parse: func(fs []string) (int, error) {
+			return strconv.Atoi(strings.Join(fs, ""))
+		},
+		Fold: true,
+	}
+}
+
+func TestSetFile(t *testing.T) {
+	fn := t.TempDir() + "/kv"
+	if err := os.WriteFile(fn, []byte("# comment\n\nB 2\na 1\n"), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+	f := newFile()
+	if err := f.SetFile(fn); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if v, ok := f.Get("b"); !ok || v != 2 {
+		t.Fatalf("%v %v", v, ok)
+	}
+	if err := f.Set("c", 3); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := f.Delete("a"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	err := f.Update("b", func(v int, ok bool) (int, bool) {
+		return v + 1, false
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if exp := "b 2\nc 3\n"; string(buf) != exp {
+		t.Fatalf("%q", buf)
+	}
+	if err := f.SetFile(""); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, ok := f.Get("b"); ok {
+		t.Fatalf("not cleared")
+	}
+	if err := f.Set("d", 4); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestSetFileMalformed(t *testing.T) {
+	fn := t.TempDir() + "/kv"
+	if err := os.WriteFile(fn, []byte("a x\n"), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := newFile().SetFile(fn); err == nil {
+		t.Fatalf("no error")
+	}
+}
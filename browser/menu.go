@@ -0,0 +1,72 @@
+package browser
+
+import (
+	"fmt"
+	"github.com/psilva261/mycel/browser/plumber"
+	"github.com/psilva261/mycel/img"
+	"github.com/psilva261/mycel/logger"
+)
+
+// menuItem of the menu for links and images
+type menuItem struct {
+	text string
+	f    func()
+}
+
+// menu with the actions for the link or image of el
+func (el *Element) menu() (items []menuItem) {
+	b := el.b
+	if u := el.link; u != nil {
+		items = append(items, menuItem{"Open", func() { b.SetAndLoadUrl(u)() }})
+		if b.OpenTab != nil {
+			items = append(items, menuItem{"Open in new tab", func() { b.OpenTab(u) }})
+		}
+		items = append(items, menuItem{"Copy link address", func() { dui.WriteSnarf([]byte(u.String())) }})
+		items = append(items, menuItem{"Plumb link", func() { b.plumb(u.String()) }})
+	}
+	if el.n.Data() == "img" {
+		src := attr(*el.n.DomSubtree, "src")
+		if _, s := srcSet(el.n); s != "" {
+			src = s
+		}
+		if src != "" && src != img.SrcZero {
+			if u, err := b.LinkedUrl(src); err == nil {
+				items = append(items, menuItem{"Copy image address", func() { dui.WriteSnarf([]byte(u.String())) }})
+				items = append(items, menuItem{"Plumb image", func() { b.plumb(u.String()) }})
+			} else {
+				log.Errorf("menu: %v", err)
+			}
+		}
+	}
+	return
+}
+
+// showMenu of el, false if there is none
+func (el *Element) showMenu() bool {
+	items := el.menu()
+	if len(items) == 0 || el.b.Menu == nil {
+		return false
+	}
+	texts := make([]string, len(items))
+	for i, it := range items {
+		texts[i] = it.text
+	}
+	res := make(chan int, 1)
+	el.b.Menu(texts, res)
+	go func() {
+		if i, ok := <-res; ok && i >= 0 && i < len(items) {
+			dui.Call <- items[i].f
+		}
+	}()
+	return true
+}
+
+// plumb s to other programs
+func (b *Browser) plumb(s string) {
+	go func() {
+		if err := plumber.Send(s); err != nil {
+			log.Errorf("plumb %v: %v", s, err)
+			b.StatusCh <- fmt.Sprintf("plumb: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,87 @@
+// Package perm stores per-site permissions keyed by origin.
+package perm
+
+import (
+	"fmt"
+	"github.com/psilva261/mycel/browser/kvfile"
+	"net/url"
+	"strings"
+)
+
+// Perm of a site
+type Perm struct {
+	JS      bool
+	Images  bool
+	Cookies bool
+}
+
+// Default for sites without stored permissions
+var Default = Perm{
+	Images:  true,
+	Cookies: true,
+}
+
+// perms are saved with one site per line, e.g.
+//
+// https://intranet.example.com js images -cookies
+var perms = kvfile.File[Perm]{
+	Format: format,
+	Parse:  parse,
+	Fold:   true,
+}
+
+// Origin of u like https://example.com:8080
+func Origin(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// Get permissions of the site u belongs to
+func Get(u *url.URL) Perm {
+	if u == nil {
+		return Default
+	}
+	if p, ok := perms.Get(Origin(u)); ok {
+		return p
+	}
+	return Default
+}
+
+// Set permissions of the site u belongs to and save them
+func Set(u *url.URL, p Perm) error {
+	return perms.Set(Origin(u), p)
+}
+
+// SetFile loads the permissions of all sites from f, changes made
+// in the site menu are stored there
+func SetFile(f string) error {
+	return perms.SetFile(f)
+}
+
+func format(p Perm) string {
+	return fmt.Sprintf("%v %v %v", flag("js", p.JS), flag("images", p.Images), flag("cookies", p.Cookies))
+}
+
+func flag(name string, on bool) string {
+	if on {
+		return name
+	}
+	return "-" + name
+}
+
+func parse(fs []string) (p Perm, err error) {
+	p = Default
+	for _, f := range fs {
+		on := !strings.HasPrefix(f, "-")
+		switch strings.TrimPrefix(f, "-") {
+		case "js":
+			p.JS = on
+		case "images":
+			p.Images = on
+		case "cookies":
+			p.Cookies = on
+		default:
+			return p, fmt.Errorf("unknown permission %v", f)
+		}
+	}
+	return
+}
@@ -0,0 +1,37 @@
+package perm
+
+import (
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestSetFile(t *testing.T) {
+	fn := t.TempDir() + "/permissions"
+	err := os.WriteFile(fn, []byte("https://app.example.com js -images\n"), 0600)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := SetFile(fn); err != nil {
+		t.Fatalf("%v", err)
+	}
+	u, _ := url.Parse("https://app.example.com/a/b")
+	if p := Get(u); !p.JS || p.Images || !p.Cookies {
+		t.Fatalf("%+v", p)
+	}
+	o, _ := url.Parse("https://other.example.com")
+	if p := Get(o); p != Default {
+		t.Fatalf("%+v", p)
+	}
+	if err := Set(o, Perm{Cookies: false}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	exp := "https://app.example.com js -images cookies\nhttps://other.example.com -js -images -cookies\n"
+	if string(buf) != exp {
+		t.Fatalf("%q", buf)
+	}
+}
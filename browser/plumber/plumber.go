@@ -0,0 +1,54 @@
+// Package plumber sends and receives messages through the plumber.
+package plumber
+
+import (
+	"9fans.net/go/plumb"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Send text to the plumber
+func Send(text string) (err error) {
+	f, err := open("send", os.O_WRONLY)
+	if err != nil {
+		return fmt.Errorf("open send: %w", err)
+	}
+	defer f.Close()
+	wd, _ := os.Getwd()
+	m := &plumb.Message{
+		Src:  "mycel",
+		Dir:  wd,
+		Type: "text",
+		Data: []byte(text),
+	}
+	if err = m.Send(f); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return
+}
+
+// Listen on port and call f with the data of each message.
+// It only returns on errors.
+func Listen(port string, f func(data string)) error {
+	p, err := open(port, os.O_RDONLY)
+	if err != nil {
+		return fmt.Errorf("open %v: %w", port, err)
+	}
+	defer p.Close()
+	return listen(bufio.NewReader(p), f)
+}
+
+func listen(r io.ByteReader, f func(data string)) error {
+	for {
+		var m plumb.Message
+		if err := m.Recv(r); err != nil {
+			return fmt.Errorf("recv: %w", err)
+		}
+		if s := strings.TrimSpace(string(m.Data)); s != "" {
+			f(s)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package plumber
+
+import (
+	"9fans.net/go/plumb"
+	"bytes"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	var buf bytes.Buffer
+	for _, d := range []string{"https://9p.io\n", "", "http://example.com"} {
+		m := &plumb.Message{Src: "test", Dst: "web", Type: "text", Data: []byte(d)}
+		if err := m.Send(&buf); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	var res []string
+	listen(&buf, func(s string) { res = append(res, s) })
+	if len(res) != 2 || res[0] != "https://9p.io" || res[1] != "http://example.com" {
+		t.Fatalf("%+v", res)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !plan9
+
+package plumber
+
+import (
+	"9fans.net/go/plan9"
+	"9fans.net/go/plumb"
+	"io"
+	"os"
+)
+
+func open(name string, mode int) (io.ReadWriteCloser, error) {
+	m := plan9.OREAD
+	if mode == os.O_WRONLY {
+		m = plan9.OWRITE
+	}
+	return plumb.Open(name, m)
+}
@@ -0,0 +1,80 @@
+package browser
+
+import (
+	"fmt"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/mycel/logger"
+	"github.com/psilva261/mycel/nodes"
+	"github.com/psilva261/mycel/style"
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// readerCSS replaces the styles of the page in reader mode
+const readerCSS = `
+body {
+  width: 700px;
+  margin-left: 20px;
+  color: #222222;
+  background-color: white;
+  font-size: 16px;
+}
+
+h1 { font-size: 28px; margin-bottom: 12px; }
+h2 { font-size: 22px; margin-top: 16px; margin-bottom: 8px; }
+h3, h4, h5, h6 { font-size: 18px; margin-top: 12px; margin-bottom: 6px; }
+
+p, ul, ol, blockquote, pre, figure { margin-bottom: 12px; }
+blockquote { margin-left: 20px; color: #555555; }
+li { margin-left: 20px; }
+pre { font-size: 14px; }
+
+img { max-width: 700px; }
+figcaption { font-size: 14px; color: #555555; }
+a { color: #1a4d99; }
+
+@media (prefers-color-scheme: dark) {
+  body { color: #dddddd; background-color: #1e1e1e; }
+  blockquote, figcaption { color: #aaaaaa; }
+  a { color: #8ab4f8; }
+}
+`
+
+// Reader is true in reader mode
+func (b *Browser) Reader() bool {
+	return b.reader
+}
+
+// ToggleReader mode and reload the page
+func (b *Browser) ToggleReader() duit.Event {
+	b.reader = !b.reader
+	return b.LoadUrl(b.URL())
+}
+
+// reader returns a page with only the main content of doc or false
+// if there is none
+func reader(doc *html.Node, nodeMap map[*html.Node]style.Map) (htm string, ok bool) {
+	body := grep(doc, "body")
+	if body == nil {
+		return
+	}
+	nt := nodes.NewNodeTree(body, style.Map{}, nodeMap, &nodes.Node{})
+	a := nt.Article()
+	if a == nil {
+		return
+	}
+	s, err := a.Serialized()
+	if err != nil {
+		log.Errorf("serialize: %v", err)
+		return
+	}
+	t := html.EscapeString(title(doc))
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html><head><title>%v</title></head><body>\n", t)
+	if t != "" && a.Find("h1") == nil {
+		fmt.Fprintf(&sb, "<h1>%v</h1>\n", t)
+	}
+	sb.WriteString(s)
+	sb.WriteString("\n</body></html>\n")
+	return sb.String(), true
+}
@@ -0,0 +1,208 @@
+// Package webfs fetches http and https URLs through webfs(4) instead
+// of net/http. Cookies, proxy settings and TLS configuration are then
+// shared with the rest of the system.
+package webfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Header fields read from the connection directory, the files are
+// named in lower case without dashes.
+var Header = []string{
+	"Content-Type",
+	"Content-Disposition",
+	"Content-Length",
+	"Last-Modified",
+	"ETag",
+	"Cache-Control",
+	"Expires",
+}
+
+// Transport for http and https URLs to register with
+// http.Transport.RegisterProtocol
+type Transport struct {
+	// open file relative to the webfs root
+	open func(name string, mode int) (io.ReadWriteCloser, error)
+}
+
+// New Transport using /mnt/web
+func New() *Transport {
+	return &Transport{open: open}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	ctl, err := t.open("clone", os.O_RDWR)
+	if err != nil {
+		return nil, fmt.Errorf("clone: %w", err)
+	}
+	buf := make([]byte, 32)
+	n, err := ctl.Read(buf)
+	if err != nil {
+		ctl.Close()
+		return nil, fmt.Errorf("read clone: %w", err)
+	}
+	c := &conn{
+		t:   t,
+		dir: strings.TrimSpace(string(buf[:n])),
+		ctl: ctl,
+	}
+	c.stop = context.AfterFunc(req.Context(), func() {
+		c.once.Do(c.release)
+	})
+	if resp, err = c.roundTrip(req); err != nil {
+		c.Close()
+	}
+	return
+}
+
+// conn is a connection directory of webfs
+type conn struct {
+	t    *Transport
+	dir  string
+	ctl  io.ReadWriteCloser
+	body io.ReadWriteCloser
+	stop func() bool
+	once sync.Once
+}
+
+func (c *conn) roundTrip(req *http.Request) (resp *http.Response, err error) {
+	if err = c.write("url " + req.URL.String()); err != nil {
+		return
+	}
+	if req.Method != "GET" {
+		if err = c.write("request " + req.Method); err != nil {
+			return
+		}
+	}
+	for k, vs := range req.Header {
+		// webfs keeps its own cookies
+		if k == "Cookie" {
+			continue
+		}
+		for _, v := range vs {
+			if err = c.write(fmt.Sprintf("headers %v: %v", k, v)); err != nil {
+				return
+			}
+		}
+	}
+	if req.Body != nil {
+		if err = c.post(req.Body); err != nil {
+			return nil, fmt.Errorf("post: %w", err)
+		}
+	}
+	c.body, err = c.t.open(c.dir+"/body", os.O_RDONLY)
+	if err != nil {
+		c.Close()
+		return failed(req, err)
+	}
+	resp = &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          c,
+		ContentLength: -1,
+		Request:       req,
+	}
+	for _, k := range Header {
+		if v, err := c.read(strings.ToLower(strings.ReplaceAll(k, "-", ""))); err == nil && v != "" {
+			resp.Header.Set(k, v)
+		}
+	}
+	if l, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = l
+	}
+	// webfs follows redirects itself
+	if v, err := c.read("parsed/url"); err == nil {
+		if u, err := url.Parse(v); err == nil && u.String() != req.URL.String() {
+			resp.Request = req.Clone(req.Context())
+			resp.Request.URL = u
+		}
+	}
+	return
+}
+
+func (c *conn) write(msg string) (err error) {
+	if _, err = io.WriteString(c.ctl, msg); err != nil {
+		return fmt.Errorf("ctl %v: %w", strings.Fields(msg)[0], err)
+	}
+	return
+}
+
+// read file in the connection directory
+func (c *conn) read(name string) (s string, err error) {
+	f, err := c.t.open(c.dir+"/"+name, os.O_RDONLY)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	buf, err := io.ReadAll(f)
+	return strings.TrimSpace(string(buf)), err
+}
+
+func (c *conn) post(r io.Reader) (err error) {
+	f, err := c.t.open(c.dir+"/postbody", os.O_WRONLY)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+func (c *conn) Read(p []byte) (n int, err error) {
+	return c.body.Read(p)
+}
+
+// Close the connection, webfs releases it when all files are closed
+func (c *conn) Close() error {
+	c.stop()
+	c.once.Do(c.release)
+	return nil
+}
+
+func (c *conn) release() {
+	if c.body != nil {
+		c.body.Close()
+	}
+	c.ctl.Close()
+}
+
+// failed request where webfs returns errors like "404 Not Found"
+func failed(req *http.Request, err error) (*http.Response, error) {
+	msg := err.Error()
+	if i := strings.LastIndex(msg, ": "); i >= 0 {
+		msg = msg[i+2:]
+	}
+	code, err2 := strconv.Atoi(strings.Fields(msg + " x")[0])
+	if err2 != nil || code < 100 || code > 599 {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	h := make(http.Header)
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	return &http.Response{
+		Status:        msg,
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader([]byte(msg))),
+		ContentLength: int64(len(msg)),
+		Request:       req,
+	}, nil
+}
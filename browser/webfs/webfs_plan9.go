@@ -0,0 +1,13 @@
+package webfs
+
+import (
+	"io"
+	"os"
+)
+
+// Available is true, webfs(4) is mounted at /mnt/web
+const Available = true
+
+func open(name string, mode int) (io.ReadWriteCloser, error) {
+	return os.OpenFile("/mnt/web/"+name, mode, 0)
+}
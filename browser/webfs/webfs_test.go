@@ -0,0 +1,235 @@
+package webfs
+
+import (
+	"9fans.net/go/plan9"
+	"9fans.net/go/plan9/client"
+	"bytes"
+	"fmt"
+	"github.com/knusbaum/go9p"
+	go9pfs "github.com/knusbaum/go9p/fs"
+	"github.com/knusbaum/go9p/proto"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeWebfs serves clone and connection directories like webfs(4)
+// and fetches with net/http
+type fakeWebfs struct {
+	mu    sync.Mutex
+	fs    *go9pfs.FS
+	root  *go9pfs.StaticDir
+	n     int
+	conns map[uint64]*fakeConn // by fid of clone
+}
+
+type fakeConn struct {
+	dir     *go9pfs.StaticDir
+	url     string
+	method  string
+	header  http.Header
+	post    bytes.Buffer
+	content []byte
+}
+
+func newFakeWebfs() (w *fakeWebfs) {
+	w = &fakeWebfs{conns: make(map[uint64]*fakeConn)}
+	w.fs, w.root = go9pfs.NewFS("glenda", "glenda", 0777, go9pfs.IgnorePermissions())
+	w.root.AddChild(&go9pfs.WrappedFile{
+		File:   go9pfs.NewBaseFile(w.fs.NewStat("clone", "glenda", "glenda", 0666)),
+		OpenF:  w.clone,
+		ReadF:  w.readClone,
+		WriteF: w.ctl,
+		CloseF: func(fid uint64) error { return nil },
+	})
+	return
+}
+
+func (w *fakeWebfs) stat(name string, mode uint32) *proto.Stat {
+	return w.fs.NewStat(name, "glenda", "glenda", mode)
+}
+
+func (w *fakeWebfs) clone(fid uint64, omode proto.Mode) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c := &fakeConn{
+		dir:    go9pfs.NewStaticDir(w.stat(fmt.Sprint(w.n), 0777|proto.DMDIR)),
+		method: "GET",
+		header: make(http.Header),
+	}
+	w.n++
+	w.conns[fid] = c
+	w.root.AddChild(c.dir)
+	c.dir.AddChild(&go9pfs.WrappedFile{
+		File: go9pfs.NewBaseFile(w.stat("postbody", 0222)),
+		WriteF: func(fid uint64, offset uint64, data []byte) (uint32, error) {
+			c.post.Write(data)
+			return uint32(len(data)), nil
+		},
+		CloseF: func(fid uint64) error { return nil },
+	})
+	body := go9pfs.NewDynamicFile(w.stat("body", 0444), func() []byte { return c.content })
+	c.dir.AddChild(&go9pfs.WrappedFile{
+		File: body,
+		OpenF: func(fid uint64, omode proto.Mode) error {
+			if err := w.fetch(c); err != nil {
+				return err
+			}
+			return body.Open(fid, omode)
+		},
+	})
+	return nil
+}
+
+func (w *fakeWebfs) readClone(fid uint64, offset uint64, count uint64) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if offset > 0 {
+		return nil, nil
+	}
+	return []byte(w.conns[fid].dir.Stat().Name + "\n"), nil
+}
+
+func (w *fakeWebfs) ctl(fid uint64, offset uint64, data []byte) (uint32, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c := w.conns[fid]
+	cmd, arg, _ := strings.Cut(string(data), " ")
+	switch cmd {
+	case "url":
+		c.url = arg
+	case "request":
+		c.method = arg
+	case "headers":
+		k, v, _ := strings.Cut(arg, ": ")
+		c.header.Add(k, v)
+	default:
+		return 0, fmt.Errorf("unknown ctl message %v", cmd)
+	}
+	return uint32(len(data)), nil
+}
+
+func (w *fakeWebfs) fetch(c *fakeConn) (err error) {
+	var body io.Reader
+	if c.method != "GET" {
+		body = &c.post
+	}
+	req, err := http.NewRequest(c.method, c.url, body)
+	if err != nil {
+		return
+	}
+	req.Header = c.header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%v", resp.Status)
+	}
+	if c.content, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	for k := range resp.Header {
+		name := strings.ToLower(strings.ReplaceAll(k, "-", ""))
+		c.dir.AddChild(go9pfs.NewStaticFile(w.stat(name, 0444), []byte(resp.Header.Get(k))))
+	}
+	parsed := go9pfs.NewStaticDir(w.stat("parsed", 0555|proto.DMDIR))
+	c.dir.AddChild(parsed)
+	parsed.AddChild(go9pfs.NewStaticFile(w.stat("url", 0444), []byte(resp.Request.URL.String())))
+	return
+}
+
+func (w *fakeWebfs) mount(t *testing.T) *Transport {
+	c1, c2 := net.Pipe()
+	go go9p.ServeReadWriter(c2, c2, w.fs.Server())
+	conn, err := client.NewConn(c1)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	fsys, err := conn.Attach(nil, "glenda", "")
+	if err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	return &Transport{
+		open: func(name string, mode int) (io.ReadWriteCloser, error) {
+			m := uint8(plan9.OREAD)
+			if mode == os.O_WRONLY {
+				m = plan9.OWRITE
+			} else if mode == os.O_RDWR {
+				m = plan9.ORDWR
+			}
+			return fsys.Open(name, m)
+		},
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, "<p>"+r.Header.Get("User-Agent")+"</p>")
+		case "/redirect":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/post":
+			buf, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "%v %v %s", r.Method, r.Header.Get("Content-Type"), buf)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tr := &http.Transport{}
+	tr.RegisterProtocol("http", newFakeWebfs().mount(t))
+	backends := map[string]*http.Client{
+		"net/http": &http.Client{},
+		"webfs":    &http.Client{Transport: tr},
+	}
+	for name, c := range backends {
+		req, _ := http.NewRequest("GET", srv.URL+"/redirect", nil)
+		req.Header.Set("User-Agent", "mycel")
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		buf, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(buf) != "<p>mycel</p>" {
+			t.Fatalf("%v: %q %v", name, buf, err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Fatalf("%v: %v", name, ct)
+		}
+		if u := resp.Request.URL.String(); u != srv.URL+"/" {
+			t.Fatalf("%v: %v", name, u)
+		}
+
+		resp, err = c.Post(srv.URL+"/post", "application/x-www-form-urlencoded", strings.NewReader("a=1"))
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		buf, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(buf) != "POST application/x-www-form-urlencoded a=1" {
+			t.Fatalf("%v: %q %v", name, buf, err)
+		}
+
+		resp, err = c.Get(srv.URL + "/missing")
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%v: %v", name, resp.Status)
+		}
+	}
+}
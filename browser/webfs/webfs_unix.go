@@ -0,0 +1,15 @@
+//go:build !plan9
+
+package webfs
+
+import (
+	"fmt"
+	"io"
+)
+
+// Available is false, webfs only exists on Plan 9
+const Available = false
+
+func open(name string, mode int) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("webfs is only available on Plan 9")
+}
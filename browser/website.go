@@ -1,9 +1,12 @@
 package browser
 
 import (
+	"9fans.net/go/draw"
 	"github.com/mjl-/duit"
 	"github.com/psilva261/mycel"
+	"github.com/psilva261/mycel/browser/block"
 	"github.com/psilva261/mycel/browser/duitx"
+	"github.com/psilva261/mycel/browser/zoom"
 	//"github.com/psilva261/mycel/browser/fs"
 	"github.com/psilva261/mycel/js"
 	"github.com/psilva261/mycel/logger"
@@ -11,77 +14,53 @@ import (
 	"github.com/psilva261/mycel/style"
 	"golang.org/x/net/html"
 	"golang.org/x/text/encoding"
+	"net/http"
 	"net/url"
+	"path/filepath"
 	"strings"
 )
 
 const (
 	InitialLayout = iota
 	ClickRelayout
+	PartialLayout // page still loading, scripts are run later
 )
 
 type Website struct {
 	b *Browser
 	duit.UI
 	mycel.ContentType
+
+	// state shown in the 9P filesystem
+	origin  string
+	htm     string
+	csss    []string
+	scripts []string
+	nt      *nodes.Node
+
+	// stylesheets by url, fetched once per page
+	sheets map[string]string
+
+	title string
 }
 
 func (w *Website) layout(f mycel.Fetcher, htm string, layouting int) {
 	defer func() {
 		w.b.StatusCh <- ""
 	}()
-	pass := func(htm string, csss ...string) (*html.Node, map[*html.Node]style.Map) {
-		if f.Ctx().Err() != nil {
-			return nil, nil
-		}
-
-		if debugPrintHtml {
-			log.Printf("%v\n", htm)
-		}
-
-		var doc *html.Node
-		var err error
-		doc, err = html.ParseWithOptions(
-			strings.NewReader(htm),
-			html.ParseOptionEnableScripting(ExperimentalJsInsecure),
-		)
-		if err != nil {
-			panic(err.Error())
-		}
-
-		log.Printf("Retrieving CSS Rules...")
-		var cssSize int
-		nodeMap := make(map[*html.Node]style.Map)
-		for i, css := range csss {
-
-			log.Printf("CSS size %v kB", cssSize/1024)
-
-			nm, err := style.FetchNodeMap(doc, css)
-			if err == nil {
-				if debugPrintHtml {
-					log.Printf("%v", nm)
-				}
-				style.MergeNodeMaps(nodeMap, nm)
-			} else {
-				log.Errorf("%v/css/%v.css: Fetch CSS Rules failed: %v", mycel.PathPrefix, i, err)
-			}
-		}
-
-		return doc, nodeMap
-	}
-
 	log.Printf("1st pass")
-	doc, _ := pass(htm)
+	doc, _ := pass(f, false, htm)
 
 	log.Printf("2nd pass")
 	log.Printf("Download style...")
-	csss := cssSrcs(f, doc)
-	doc, nodeMap := pass(htm, csss...)
+	csss := cssSrcs(f, doc, w.sheets)
+	scripting := w.b.jsEnabled()
+	doc, nodeMap := pass(f, scripting, htm, csss...)
 
 	// 3rd pass is only needed initially to load the scripts and set the js VM
 	// state. During subsequent calls from click handlers that state is kept.
 	var scripts []string
-	if ExperimentalJsInsecure && layouting != ClickRelayout {
+	if scripting && layouting == InitialLayout {
 		var (
 			jsProcessed string
 			changed bool
@@ -98,8 +77,11 @@ func (w *Website) layout(f mycel.Fetcher, htm string, layouting int) {
 				log.Printf("error parsing %v", src)
 				continue
 			}
+			if w.b.Block(url, block.Script) {
+				continue
+			}
 			log.Printf("Download %v", url)
-			buf, _, err := f.Get(url)
+			buf, _, err := getAs(f, url, block.Script)
 			if err != nil {
 				log.Printf("error downloading %v", url)
 				continue
@@ -107,8 +89,7 @@ func (w *Website) layout(f mycel.Fetcher, htm string, layouting int) {
 			downloads[src] = string(buf)
 		}
 		scripts = js.Scripts(nt, downloads)
-		w.b.fs.Update(f.Origin().String(), htm, csss, scripts)
-		w.b.fs.SetDOM(nt)
+		w.setFS(f.Origin().String(), htm, csss, scripts, nt)
 		log.Infof("JS pipeline start")
 		w.b.js.Stop()
 		w.b.js, jsProcessed, changed, err = processJS2(f)
@@ -117,7 +98,7 @@ func (w *Website) layout(f mycel.Fetcher, htm string, layouting int) {
 			if debugPrintHtml {
 				log.Printf("%v\n", jsProcessed)
 			}
-			doc, nodeMap = pass(htm, csss...)
+			doc, nodeMap = pass(f, scripting, htm, csss...)
 		} else if err != nil {
 			log.Errorf("JS error: %v", err)
 		}
@@ -126,6 +107,15 @@ func (w *Website) layout(f mycel.Fetcher, htm string, layouting int) {
 	if f.Ctx().Err() != nil {
 		return
 	}
+	if w.b.reader {
+		if rd, ok := reader(doc, nodeMap); ok {
+			htm = rd
+			csss = []string{style.AddOnCSS, readerCSS}
+			doc, nodeMap = pass(f, scripting, htm, csss...)
+		} else {
+			log.Infof("reader: no article found")
+		}
+	}
 	var countHtmlNodes func(*html.Node) int
 	countHtmlNodes = func(n *html.Node) (num int) {
 		num++
@@ -135,6 +125,7 @@ func (w *Website) layout(f mycel.Fetcher, htm string, layouting int) {
 		return
 	}
 	log.Printf("%v html nodes found...", countHtmlNodes(doc))
+	w.title = title(doc)
 
 	body := grep(doc, "body")
 	if body == nil {
@@ -144,30 +135,192 @@ func (w *Website) layout(f mycel.Fetcher, htm string, layouting int) {
 	}
 
 	log.Printf("Layout website...")
-	nt := nodes.NewNodeTree(body, style.Map{}, nodeMap, &nodes.Node{})
-	if scroller != nil {
-		scroller.Free()
-		scroller = nil
+	page := &style.Page{
+		Zoom:     zoom.Get(f.Origin()),
+		Inverted: style.DetectDark(csss),
 	}
-	scroller = duitx.NewScroll(dui, NodeToBox(0, w.b, nt))
+	nt := nodes.NewNodeTree(body, style.Map{}, nodeMap, &nodes.Node{})
+	nt.SetPage(page)
+	hideElements(nt, f.Origin().Hostname())
+	w.build(nt)
 	numElements := 0
-	TraverseTree(scroller, func(ui duit.UI) {
+	TraverseTree(w.b.scroller, func(ui duit.UI) {
 		numElements++
 	})
-	w.UI = scroller
 	log.Printf("Layouting done (%v elements created)", numElements)
-	if numElements < 10 {
+	if numElements < 10 && layouting != PartialLayout {
 		log.Errorf("Less than 10 elements layouted, seems css processing failed. Will layout without css")
 		nt = nodes.NewNodeTree(body, style.Map{}, make(map[*html.Node]style.Map), nil)
-		scroller = duitx.NewScroll(dui, NodeToBox(0, w.b, nt))
-		w.UI = scroller
+		nt.SetPage(page)
+		w.build(nt)
+	}
+
+	w.setFS(f.Origin().String(), htm, csss, scripts, nt)
+}
+
+// build the UI of the node tree nt
+func (w *Website) build(nt *nodes.Node) {
+	w.b.radios = nil
+	w.b.focused = nil
+	w.b.hints = nil
+	if w.b.scroller != nil {
+		w.b.scroller.Free()
+		w.b.scroller = nil
+	}
+	w.b.scroller = duitx.NewScroll(dui, NodeToBox(0, w.b, nt))
+	w.UI = w.b.scroller
+}
+
+// relayout the node tree with the zoom level of the site, e.g.
+// after zooming. Nothing is fetched except for images.
+func (w *Website) relayout() {
+	if w.nt == nil {
+		return
+	}
+	w.nt.SetPage(&style.Page{
+		Zoom:     zoom.Get(w.b.Origin()),
+		Inverted: style.DetectDark(w.csss),
+	})
+	w.b.imageCache = make(map[string]*draw.Image)
+	w.b.found = nil
+	w.build(w.nt)
+}
+
+func (w *Website) setFS(origin, htm string, csss, scripts []string, nt *nodes.Node) {
+	w.origin = origin
+	w.htm = htm
+	w.csss = csss
+	w.scripts = scripts
+	w.nt = nt
+	w.updateFS()
+}
+
+// updateFS if the website's tab is the active one
+func (w *Website) updateFS() {
+	if w.b.fs == nil || w.b.fs.Fetcher != mycel.Fetcher(w.b) {
+		return
 	}
+	var c *http.Client
+	if w.b.jsEnabled() {
+		c = xhrClient
+	}
+	w.b.fs.SetClient(c)
+	w.b.fs.Update(w.origin, w.htm, w.csss, w.scripts)
+	w.b.fs.SetDOM(w.nt)
+}
 
-	w.b.fs.Update(f.Origin().String(), htm, csss, scripts)
-	w.b.fs.SetDOM(nt)
+// xhrClient for requests of scripts on sites with JS enabled
+var xhrClient = &http.Client{}
+
+// title of the document doc
+func title(doc *html.Node) string {
+	h := grep(doc, "head")
+	if h == nil {
+		return ""
+	}
+	t := grep(h, "title")
+	if t == nil || t.FirstChild == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(t.FirstChild.Data), " ")
 }
 
-func cssSrcs(f mycel.Fetcher, doc *html.Node) (srcs []string) {
+// hideElements matching the element hiding rules for host
+// selectors of elements to hide on host
+var selectors = block.Selectors
+
+func hideElements(nt *nodes.Node, host string) {
+	keys := make(map[string]bool)
+	nt.Traverse(func(_ int, n *nodes.Node) {
+		if n.Type() != html.ElementNode {
+			return
+		}
+		if id := n.Attr("id"); id != "" {
+			keys["#"+id] = true
+		}
+		for _, c := range strings.Fields(n.Attr("class")) {
+			keys["."+c] = true
+		}
+	})
+	sels := selectors(host, func(k string) bool { return keys[k] })
+	// query in batches, one by one if a batch has unsupported selectors
+	for i := 0; i < len(sels); i += 100 {
+		batch := sels[i:min(i+100, len(sels))]
+		ns, err := nt.Query(strings.Join(batch, ", "))
+		if err != nil {
+			ns = nil
+			for _, sel := range batch {
+				res, err := nt.Query(sel)
+				if err != nil {
+					log.Printf("hide %v: %v", sel, err)
+					continue
+				}
+				ns = append(ns, res...)
+			}
+		}
+		for _, n := range ns {
+			n.SetCss("display", "none")
+		}
+	}
+}
+
+// pass parses htm and applies the stylesheets csss. With scripting
+// noscript elements are parsed as raw text.
+func pass(f mycel.Fetcher, scripting bool, htm string, csss ...string) (*html.Node, map[*html.Node]style.Map) {
+	if f.Ctx().Err() != nil {
+		return nil, nil
+	}
+
+	if debugPrintHtml {
+		log.Printf("%v\n", htm)
+	}
+
+	var doc *html.Node
+	var err error
+	doc, err = html.ParseWithOptions(
+		strings.NewReader(htm),
+		html.ParseOptionEnableScripting(scripting),
+	)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	log.Printf("Retrieving CSS Rules...")
+	var cssSize int
+	nodeMap := make(map[*html.Node]style.Map)
+	for i, css := range csss {
+
+		log.Printf("CSS size %v kB", cssSize/1024)
+
+		nm, err := style.FetchNodeMap(doc, css)
+		if err == nil {
+			if debugPrintHtml {
+				log.Printf("%v", nm)
+			}
+			style.MergeNodeMaps(nodeMap, nm)
+		} else {
+			log.Errorf("%v/css/%v.css: Fetch CSS Rules failed: %v", mycel.PathPrefix, i, err)
+		}
+	}
+
+	// user stylesheets, not when only parsing for the page's styles
+	if u := f.Origin(); len(csss) > 0 && u != nil {
+		for _, css := range style.UserCSS(u.Hostname()) {
+			nm, err := style.FetchUserNodeMap(doc, css)
+			if err != nil {
+				log.Errorf("user css: %v", err)
+				continue
+			}
+			style.MergeNodeMaps(nodeMap, nm)
+		}
+	}
+
+	return doc, nodeMap
+}
+
+// cssSrcs of doc, fetched stylesheets are looked up and stored in
+// sheets unless it is nil
+func cssSrcs(f mycel.Fetcher, doc *html.Node, sheets map[string]string) (srcs []string) {
 	srcs = make([]string, 0, 20)
 	srcs = append(srcs, style.AddOnCSS)
 	ntAll := nodes.NewNodeTree(doc, style.Map{}, make(map[*html.Node]style.Map), nil)
@@ -195,13 +348,20 @@ func cssSrcs(f mycel.Fetcher, doc *html.Node) (srcs []string) {
 					log.Errorf("error parsing %v", href)
 					return
 				}
-				buf, contentType, err := f.Get(url)
+				if css, ok := sheets[url.String()]; ok {
+					srcs = append(srcs, css)
+					return
+				}
+				buf, contentType, err := getAs(f, url, block.Stylesheet)
 				if err != nil {
 					log.Errorf("error downloading %v", url)
 					return
 				}
 				if contentType.IsCSS() {
 					srcs = append(srcs, string(buf))
+					if sheets != nil {
+						sheets[url.String()] = string(buf)
+					}
 				} else {
 					log.Printf("css: unexpected %v", contentType)
 				}
@@ -215,14 +375,47 @@ func formData(n, submitBtn *html.Node) (data url.Values) {
 	data = make(url.Values)
 	nm := attr(*n, "name")
 
+	if n.Type == html.ElementNode && hasAttr(*n, "disabled") {
+		return
+	}
+
 	switch n.Data {
-	case "input", "select":
-		if attr(*n, "type") == "submit" && n != submitBtn {
+	case "input":
+		switch attr(*n, "type") {
+		case "submit", "image":
+			if n != submitBtn {
+				return
+			}
+		case "reset", "button", "file":
+			return
+		case "checkbox", "radio":
+			if !hasAttr(*n, "checked") {
+				return
+			}
+			if nm != "" {
+				v := attr(*n, "value")
+				if v == "" {
+					v = "on"
+				}
+				data.Set(nm, v)
+			}
 			return
 		}
 		if nm != "" {
 			data.Set(nm, attr(*n, "value"))
 		}
+	case "button":
+		if n == submitBtn && nm != "" {
+			data.Set(nm, attr(*n, "value"))
+		}
+		return
+	case "select":
+		if nm != "" {
+			for _, v := range selectedOptions(n) {
+				data.Add(nm, v)
+			}
+		}
+		return
 	case "textarea":
 		nn := nodes.NewNodeTree(n, style.Map{}, make(map[*html.Node]style.Map), nil)
 
@@ -242,6 +435,62 @@ func formData(n, submitBtn *html.Node) (data url.Values) {
 	return
 }
 
+// selectedOptions of a select element. Without selected option the
+// first one is used unless multiple values are allowed.
+func selectedOptions(sel *html.Node) (vs []string) {
+	var first *string
+	var f func(n *html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "option" {
+			v := optionValue(n)
+			if first == nil && !hasAttr(*n, "disabled") {
+				first = &v
+			}
+			if hasAttr(*n, "selected") {
+				vs = append(vs, v)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(sel)
+	if len(vs) == 0 && first != nil && !hasAttr(*sel, "multiple") {
+		vs = append(vs, *first)
+	}
+	return
+}
+
+func optionValue(opt *html.Node) string {
+	if hasAttr(*opt, "value") {
+		return attr(*opt, "value")
+	}
+	nn := nodes.NewNodeTree(opt, style.Map{}, make(map[*html.Node]style.Map), nil)
+	return strings.TrimSpace(nn.ContentString(false))
+}
+
+// formFiles maps the names of file inputs to the paths the user
+// picked, other inputs are sent empty
+func formFiles(n *html.Node, picked map[*html.Node]string) (files url.Values) {
+	files = make(url.Values)
+	if n.Type == html.ElementNode && hasAttr(*n, "disabled") {
+		return
+	}
+	if n.Data == "input" && attr(*n, "type") == "file" {
+		if nm := attr(*n, "name"); nm != "" {
+			files.Add(nm, picked[n])
+		}
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		for k, vs := range formFiles(c, picked) {
+			files[k] = append(files[k], vs...)
+		}
+	}
+	return
+}
+
 func escapeValues(ct mycel.ContentType, q url.Values) (qe url.Values) {
 	qe = make(url.Values)
 	enc := encoding.HTMLEscapeUnsupported(ct.Encoding().NewEncoder())
@@ -270,37 +519,78 @@ func (b *Browser) submit(form *html.Node, submitBtn *html.Node) {
 	var buf []byte
 	var contentType mycel.ContentType
 
-	method := "GET" // TODO
+	method := "GET"
 	if m := attr(*form, "method"); m != "" {
 		method = strings.ToUpper(m)
 	}
+	action := attr(*form, "action")
+	enctype := attr(*form, "enctype")
+	if submitBtn != nil {
+		if m := attr(*submitBtn, "formmethod"); m != "" {
+			method = strings.ToUpper(m)
+		}
+		if a := attr(*submitBtn, "formaction"); a != "" {
+			action = a
+		}
+		if e := attr(*submitBtn, "formenctype"); e != "" {
+			enctype = e
+		}
+	}
 	uri := b.URL()
-	if action := attr(*form, "action"); action != "" {
+	if action != "" {
 		uri, err = b.LinkedUrl(action)
 		if err != nil {
 			log.Printf("error parsing %v", action)
 			return
 		}
 	}
+	if !b.linkable(uri) {
+		log.Errorf("submit: %v not allowed from %v", uri, b.URL())
+		return
+	}
 
-	if method == "GET" {
-		q := uri.Query()
-		for k, vs := range formData(form, submitBtn) {
-			q.Set(k, vs[0]) // TODO: what is with the rest?
-		}
-		uri.RawQuery = escapeValues(b.Website.ContentType, q).Encode()
-		buf, contentType, err = b.get(uri, true)
+	data := formData(form, submitBtn)
+	files := formFiles(form, b.files)
+	if method == "POST" && strings.ToLower(enctype) == "multipart/form-data" {
+		buf, contentType, err = b.PostMultipart(uri, data, files)
 	} else {
-		buf, contentType, err = b.PostForm(uri, formData(form, submitBtn))
+		// without multipart encoding only the file names are sent
+		for k, fns := range files {
+			for _, fn := range fns {
+				if fn != "" {
+					fn = filepath.Base(fn)
+				}
+				data.Add(k, fn)
+			}
+		}
+		if method == "GET" && (uri.Scheme == "gemini" || uri.Scheme == "gopher") {
+			// input prompts send their only field as query
+			uri.RawQuery = ""
+			for _, vs := range data {
+				uri.RawQuery = url.PathEscape(vs[0])
+			}
+			buf, contentType, err = b.get(uri, true)
+		} else if method == "GET" {
+			q := uri.Query()
+			for k, vs := range data {
+				q[k] = vs
+			}
+			uri.RawQuery = escapeValues(b.Website.ContentType, q).Encode()
+			buf, contentType, err = b.get(uri, true)
+		} else {
+			buf, contentType, err = b.PostForm(uri, data)
+		}
 	}
 
 	if err != nil {
 		log.Errorf("submit form: %v", err)
+		b.loading = false
 		return
 	}
 
 	if !contentType.IsHTML() {
 		log.Errorf("post: unexpected %v", contentType)
+		b.loading = false
 		return
 	}
 
@@ -1,9 +1,15 @@
 package browser
 
 import (
+	"context"
+	"fmt"
 	"github.com/psilva261/mycel"
 	"golang.org/x/net/html"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
 	"strings"
 	"testing"
 )
@@ -69,3 +75,143 @@ func TestPercentEncoding(t *testing.T) {
 		t.Errorf("%v", res)
 	}
 }
+
+func TestFormDataTypes(t *testing.T) {
+	htm := `<form>
+		<input type=hidden name=h value=x>
+		<input type=checkbox name=c value=1 checked>
+		<input type=checkbox name=c value=2>
+		<input type=checkbox name=d checked>
+		<input type=radio name=r value=a>
+		<input type=radio name=r value=b checked>
+		<input type=number name=n value=3 disabled>
+		<select name=s multiple>
+			<option selected>one</option>
+			<optgroup><option value=2 selected>two</option></optgroup>
+			<option>three</option>
+		</select>
+		<select name=t><option value=u>U</option><option>V</option></select>
+		<input type=file name=f value=/tmp/a.txt>
+		<input type=reset name=x>
+		<button name=btn value=go>Go</button>
+		<input type=submit name=sub value=Send>
+	</form>`
+	doc, err := html.Parse(strings.NewReader(htm))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	f := grep(doc, "form")
+	data := formData(f, grep(doc, "button"))
+	exp := "btn=go&c=1&d=on&h=x&r=b&s=one&s=2&t=u"
+	if res := data.Encode(); res != exp {
+		t.Fatalf("%v", res)
+	}
+	// the page must not choose which local file is uploaded
+	var fi *html.Node
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.Data == "input" && attr(*n, "type") == "file" {
+			fi = n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(f)
+	if files := formFiles(f, nil); len(files["f"]) != 1 || files.Get("f") != "" {
+		t.Fatalf("%+v", files)
+	}
+	if files := formFiles(f, map[*html.Node]string{fi: "/tmp/b.txt"}); files.Get("f") != "/tmp/b.txt" {
+		t.Fatalf("%+v", files)
+	}
+}
+
+func TestPostMultipart(t *testing.T) {
+	fn := t.TempDir() + "/a.txt"
+	if err := os.WriteFile(fn, []byte("content"), 0600); err != nil {
+		t.Fatalf(err.Error())
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, h, err := r.FormFile("f")
+		if err != nil {
+			t.Errorf("form file: %v", err)
+			return
+		}
+		defer f.Close()
+		buf, _ := io.ReadAll(f)
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%v %v %v", r.FormValue("a"), h.Filename, string(buf))
+	}))
+	defer ts.Close()
+
+	b := &Browser{
+		client:   ts.Client(),
+		StatusCh: make(chan string, 10),
+		Website:  &Website{},
+	}
+	b.ctx = context.Background()
+	uri, _ := url.Parse(ts.URL)
+	buf, _, err := b.PostMultipart(uri, url.Values{"a": {"1"}}, url.Values{"f": {fn}})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if string(buf) != "1 a.txt content" {
+		t.Fatalf("%v", string(buf))
+	}
+}
+
+func TestReader(t *testing.T) {
+	p := "<p>The window system, rio, gives each window its own files, so programs can be written against the files alone.</p>"
+	htm := `<html><head><title>Rio &amp; windows</title></head><body>
+		<nav><a href="/">Home</a></nav>
+		<article><div class="post">` + p + p + p + `</div></article>
+		<aside><p>Subscribe to our newsletter, it is great, really great, honestly.</p></aside>
+	</body></html>`
+	doc, err := html.Parse(strings.NewReader(htm))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rd, ok := reader(doc, nil)
+	if !ok {
+		t.Fatalf("no article")
+	}
+	if !strings.Contains(rd, "<h1>Rio &amp; windows</h1>") || !strings.Contains(rd, `<div class="post">`) ||
+		strings.Contains(rd, "Home") || strings.Contains(rd, "newsletter") {
+		t.Fatalf("%v", rd)
+	}
+	doc, _ = html.Parse(strings.NewReader(`<html><body><p>Short</p></body></html>`))
+	if _, ok := reader(doc, nil); ok {
+		t.Fatalf("article found")
+	}
+}
+
+type cssFetcher struct {
+	TestFetcher
+	n int
+}
+
+func (f *cssFetcher) LinkedUrl(addr string) (*url.URL, error) {
+	return f.Origin().Parse(addr)
+}
+
+func (f *cssFetcher) Get(*url.URL) ([]byte, mycel.ContentType, error) {
+	f.n++
+	return []byte("p { color: red; }"), mycel.ContentType{MediaType: "text/css"}, nil
+}
+
+func TestCssSrcsSheets(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><head><link rel="stylesheet" href="/a.css"></head><body></body></html>`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	f := &cssFetcher{}
+	sheets := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		if srcs := cssSrcs(f, doc, sheets); len(srcs) != 2 || srcs[1] != "p { color: red; }" {
+			t.Fatalf("%+v", srcs)
+		}
+	}
+	if f.n != 1 || sheets["https://example.com/a.css"] == "" {
+		t.Fatalf("%v %+v", f.n, sheets)
+	}
+}
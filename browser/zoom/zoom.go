@@ -0,0 +1,76 @@
+// Package zoom stores the zoom level of sites keyed by origin.
+package zoom
+
+import (
+	"fmt"
+	"github.com/psilva261/mycel/browser/kvfile"
+	"github.com/psilva261/mycel/browser/perm"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Levels to step through when zooming in and out
+var Levels = []float64{0.5, 0.67, 0.8, 0.9, 1, 1.1, 1.25, 1.5, 1.75, 2, 2.5, 3}
+
+// zooms other than 1 are saved with one site per line, e.g.
+//
+// https://grafana.example.com 1.5
+var zooms = kvfile.File[float64]{
+	Format: func(z float64) string {
+		return strconv.FormatFloat(z, 'g', -1, 64)
+	},
+	Parse: parse,
+	Fold:  true,
+}
+
+// Get zoom level of the site u belongs to
+func Get(u *url.URL) float64 {
+	if u == nil {
+		return 1
+	}
+	if z, ok := zooms.Get(perm.Origin(u)); ok {
+		return z
+	}
+	return 1
+}
+
+// Set zoom level of the site u belongs to and save it
+func Set(u *url.URL, z float64) error {
+	if z == 1 {
+		return zooms.Delete(perm.Origin(u))
+	}
+	return zooms.Set(perm.Origin(u), z)
+}
+
+// Step from z to the next level, to the previous one if delta
+// is negative. A delta of 0 resets the zoom.
+func Step(z float64, delta int) float64 {
+	if delta == 0 {
+		return 1
+	}
+	i := sort.SearchFloat64s(Levels, z)
+	if delta > 0 && i < len(Levels) && Levels[i] == z {
+		i++
+	} else if delta < 0 {
+		i--
+	}
+	return Levels[max(0, min(i, len(Levels)-1))]
+}
+
+// SetFile loads the zoom level of sites from f, levels picked with
+// the zoom keys are stored there
+func SetFile(f string) error {
+	return zooms.SetFile(f)
+}
+
+func parse(fs []string) (z float64, err error) {
+	if len(fs) != 1 {
+		return 0, fmt.Errorf("malformed line")
+	}
+	z, err = strconv.ParseFloat(fs[0], 64)
+	if err != nil || z <= 0 {
+		return 0, fmt.Errorf("invalid zoom %v", fs[0])
+	}
+	return
+}
@@ -0,0 +1,59 @@
+package zoom
+
+import (
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestSetFile(t *testing.T) {
+	fn := t.TempDir() + "/zoom"
+	if err := os.WriteFile(fn, []byte("https://grafana.example.com 1.5\n"), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := SetFile(fn); err != nil {
+		t.Fatalf("%v", err)
+	}
+	u, _ := url.Parse("https://grafana.example.com/d/1")
+	if z := Get(u); z != 1.5 {
+		t.Fatalf("%v", z)
+	}
+	o, _ := url.Parse("https://other.example.com")
+	if z := Get(o); z != 1 {
+		t.Fatalf("%v", z)
+	}
+	if err := Set(o, 0.8); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := Set(u, 1); err != nil {
+		t.Fatalf("%v", err)
+	}
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if exp := "https://other.example.com 0.8\n"; string(buf) != exp {
+		t.Fatalf("%q", buf)
+	}
+}
+
+func TestStep(t *testing.T) {
+	rows := []struct {
+		z     float64
+		delta int
+		exp   float64
+	}{
+		{1, 1, 1.1},
+		{1, -1, 0.9},
+		{1.2, 1, 1.25},
+		{1.2, -1, 1.1},
+		{3, 1, 3},
+		{0.5, -1, 0.5},
+		{2, 0, 1},
+	}
+	for _, r := range rows {
+		if z := Step(r.z, r.delta); z != r.exp {
+			t.Errorf("%+v: %v", r, z)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/mycel/browser"
+	"github.com/psilva261/mycel/logger"
+	"github.com/psilva261/mycel/style"
+	"image/png"
+	"net/url"
+	"os"
+	"time"
+)
+
+var (
+	headless bool
+	out      string
+	width    = 1024
+)
+
+// settle is the time without UI calls after loading before the
+// snapshot is taken, so that asynchronously loaded images are in.
+const settle = 500 * time.Millisecond
+
+// Headless renders loc offscreen and writes it as PNG to out. The
+// user's profile isn't read or changed.
+func Headless() (err error) {
+	dui, err = duit.NewDUI("mycel", &duit.DUIOpts{
+		Dimensions: fmt.Sprintf("%dx%d", width, style.WindowHeight),
+	})
+	if err != nil {
+		return fmt.Errorf("new dui: %w", err)
+	}
+	dui.Debug = dbg
+	style.WindowWidth = width
+	style.Init(dui)
+	dui.Top.UI = &duit.Label{}
+
+	u, err := browser.ParseLocation(loc)
+	if err != nil {
+		return fmt.Errorf("parse url: %w", err)
+	}
+	if b, err = browser.NewHeadless(dui, u); err != nil {
+		return fmt.Errorf("new browser: %w", err)
+	}
+	b.Download = func(u *url.URL, fn string, res chan *string) {
+		close(res)
+	}
+	dui.Top.UI = b.Website
+
+	t := time.NewTimer(settle)
+	for {
+		select {
+		case e := <-dui.Inputs:
+			dui.Input(e)
+			if !t.Stop() {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+			t.Reset(settle)
+		case <-b.LocCh:
+		case msg := <-b.StatusCh:
+			if msg != "" {
+				log.Infof("%v", msg)
+			}
+		case err := <-dui.Error:
+			log.Errorf("headless: duit: %v", err)
+		case <-t.C:
+			if b.Loading() {
+				t.Reset(settle)
+				continue
+			}
+			return snapshot()
+		}
+	}
+}
+
+func snapshot() (err error) {
+	i, err := b.Snapshot(width)
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, i); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	return
+}
@@ -5,15 +5,23 @@ import (
 	"fmt"
 	"github.com/mjl-/duit"
 	"github.com/psilva261/mycel/browser"
+	"github.com/psilva261/mycel/browser/bookmarks"
+	"github.com/psilva261/mycel/browser/downloads"
+	"github.com/psilva261/mycel/browser/perm"
+	"github.com/psilva261/mycel/browser/plumber"
+	"github.com/psilva261/mycel/browser/webfs"
+	"github.com/psilva261/mycel/browser/zoom"
 	"github.com/psilva261/mycel/js"
 	"github.com/psilva261/mycel/logger"
 	"github.com/psilva261/mycel/style"
 	"image"
+	"math"
 	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -23,8 +31,12 @@ var (
 	b          *browser.Browser
 	cpuprofile string
 	memprofile string
-	loc        string = "http://9p.io"
+	loc        string
+	finding    bool
+	findText   string
 	dbg        bool
+	dump       bool
+	cols       = 80
 	v          View
 	Style      = style.Map{}
 )
@@ -40,6 +52,8 @@ type View interface {
 type Nav struct {
 	LocationField *duit.Field
 	StatusBar     *duit.Label
+	FindField     *duit.Field
+	FindLabel     *duit.Label
 }
 
 func NewNav() (n *Nav) {
@@ -53,16 +67,26 @@ func NewNav() (n *Nav) {
 		Font: Style.Font(),
 		Keys: n.keys,
 	}
+	n.FindField = &duit.Field{
+		Text:        findText,
+		Placeholder: "Find in page",
+		Font:        Style.Font(),
+		Keys:        n.findKeys,
+		Changed: func(t string) (e duit.Event) {
+			findText = t
+			n.found(0, b.Find(t))
+			return
+		},
+	}
+	n.FindLabel = &duit.Label{
+		Font: browser.Style.Font(),
+	}
 	return
 }
 
 func (n *Nav) keys(k rune, m draw.Mouse) (e duit.Event) {
 	if k == browser.EnterKey && !b.Loading() {
-		a := n.LocationField.Text
-		if !strings.HasPrefix(strings.ToLower(a), "http") {
-			a = "http://" + a
-		}
-		u, err := url.Parse(a)
+		u, err := browser.ParseLocation(n.LocationField.Text)
 		if err != nil {
 			log.Errorf("parse url: %v", err)
 			return
@@ -72,18 +96,203 @@ func (n *Nav) keys(k rune, m draw.Mouse) (e duit.Event) {
 	return
 }
 
+// bookmark the current page
+func (n *Nav) bookmark() {
+	u := b.URL().String()
+	if u == bookmarks.URL {
+		return
+	}
+	if err := bookmarks.Add(u, b.Title()); err != nil {
+		log.Errorf("bookmark: %v", err)
+		n.StatusBar.Text += fmt.Sprintf("Bookmark: %v\n", err)
+	} else {
+		n.StatusBar.Text += "Bookmarked\n"
+	}
+	dui.MarkLayout(n.StatusBar)
+	dui.MarkDraw(n.StatusBar)
+}
+
+func (n *Nav) findKeys(k rune, m draw.Mouse) (e duit.Event) {
+	switch k {
+	case browser.EnterKey, draw.KeyDown:
+		n.found(b.FindNext(1))
+		e.Consumed = true
+	case draw.KeyUp:
+		n.found(b.FindNext(-1))
+		e.Consumed = true
+	case draw.KeyEscape:
+		n.closeFind()
+		e.Consumed = true
+	}
+	return
+}
+
+// found updates the match counter
+func (n *Nav) found(i, num int) {
+	if num == 0 {
+		n.FindLabel.Text = "no matches"
+	} else {
+		n.FindLabel.Text = fmt.Sprintf("%d/%d", i+1, num)
+	}
+	if findText == "" {
+		n.FindLabel.Text = ""
+	}
+	dui.MarkLayout(n.FindLabel)
+	dui.MarkDraw(n.FindLabel)
+}
+
+// find q like typed into the find field, used by the ctl file
+func (n *Nav) find(q string) (num int) {
+	findText = q
+	n.FindField.Text = q
+	n.FindField.Cursor1 = 0
+	n.FindField.SelectionStart1 = 0
+	num = b.Find(q)
+	n.found(0, num)
+	if !finding {
+		finding = true
+		render()
+	}
+	return
+}
+
+func (n *Nav) openFind() {
+	if !finding {
+		finding = true
+		render()
+	}
+	dui.Focus(n.FindField)
+}
+
+func (n *Nav) closeFind() {
+	finding = false
+	findText = ""
+	b.ClearFind()
+	render()
+}
+
+func (n *Nav) findBar() duit.UI {
+	return &duit.Grid{
+		Columns: 5,
+		Halign:  []duit.Halign{duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignLeft},
+		Valign:  []duit.Valign{duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle},
+		Kids: duit.NewKids(
+			&duit.Button{
+				Text: "Previous",
+				Font: browser.Style.Font(),
+				Click: func() (e duit.Event) {
+					n.found(b.FindNext(-1))
+					e.Consumed = true
+					return
+				},
+			},
+			&duit.Button{
+				Text: "Next",
+				Font: browser.Style.Font(),
+				Click: func() (e duit.Event) {
+					n.found(b.FindNext(1))
+					e.Consumed = true
+					return
+				},
+			},
+			&duit.Button{
+				Text: "Close",
+				Font: browser.Style.Font(),
+				Click: func() (e duit.Event) {
+					n.closeFind()
+					e.Consumed = true
+					return
+				},
+			},
+			&duit.Box{
+				Width: 300,
+				Kids:  duit.NewKids(n.FindField),
+			},
+			n.FindLabel,
+		),
+	}
+}
+
 func (n *Nav) Render() []*duit.Kid {
 	uis := []duit.UI{
+		tabBar(),
 		&duit.Grid{
-			Columns: 3,
-			Halign:  []duit.Halign{duit.HalignLeft, duit.HalignLeft, duit.HalignRight},
-			Valign:  []duit.Valign{duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle},
+			Columns: 10,
+			Halign:  []duit.Halign{duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignLeft, duit.HalignRight},
+			Valign:  []duit.Valign{duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle},
 			Kids: duit.NewKids(
 				&duit.Button{
 					Text:  "Back",
 					Font:  browser.Style.Font(),
 					Click: b.Back,
 				},
+				&duit.Button{
+					Text:  "Forward",
+					Font:  browser.Style.Font(),
+					Click: b.Forward,
+				},
+				&duit.Button{
+					Text: "History",
+					Font: browser.Style.Font(),
+					Click: func() (e duit.Event) {
+						v = &HistoryView{}
+						render()
+						e.Consumed = true
+						return
+					},
+				},
+				&duit.Button{
+					Text: "Bookmark",
+					Font: browser.Style.Font(),
+					Click: func() (e duit.Event) {
+						n.bookmark()
+						e.Consumed = true
+						return
+					},
+				},
+				&duit.Button{
+					Text: "Bookmarks",
+					Font: browser.Style.Font(),
+					Click: func() (e duit.Event) {
+						u, err := url.Parse(bookmarks.URL)
+						if err != nil {
+							log.Errorf("parse: %v", err)
+							return
+						}
+						return b.LoadUrl(u)
+					},
+				},
+				&duit.Button{
+					Text: "Downloads",
+					Font: browser.Style.Font(),
+					Click: func() (e duit.Event) {
+						u, err := url.Parse(downloads.URL)
+						if err != nil {
+							log.Errorf("parse: %v", err)
+							return
+						}
+						return b.LoadUrl(u)
+					},
+				},
+				&duit.Button{
+					Text: readerText(),
+					Font: browser.Style.Font(),
+					Click: func() (e duit.Event) {
+						e = b.ToggleReader()
+						render()
+						return
+					},
+				},
+				&duit.Button{
+					Text: "Site",
+					Font: browser.Style.Font(),
+					Click: func() (e duit.Event) {
+						v = NewSiteView()
+						render()
+						e.Consumed = true
+						return
+					},
+				},
 				&duit.Button{
 					Text:  "Stop",
 					Font:  browser.Style.Font(),
@@ -103,12 +312,222 @@ func (n *Nav) Render() []*duit.Kid {
 		},
 		n.StatusBar,
 	}
+	if finding {
+		uis = append(uis, n.findBar())
+	}
 	if b != nil {
 		uis = append(uis, b.Website)
 	}
 	return duit.NewKids(uis...)
 }
 
+// readerText of the button toggling reader mode
+func readerText() string {
+	if b != nil && b.Reader() {
+		return "Original"
+	}
+	return "Reader"
+}
+
+type HistoryView struct{}
+
+func (h *HistoryView) Render() []*duit.Kid {
+	its, cur := b.History.Items()
+	l := &duit.List{
+		Values: make([]*duit.ListValue, 0, len(its)),
+		Font:   browser.Style.Font(),
+	}
+	// most recent first
+	for i := len(its) - 1; i >= 0; i-- {
+		l.Values = append(l.Values, &duit.ListValue{
+			Text:     its[i].URL.String(),
+			Value:    i,
+			Selected: i == cur,
+		})
+	}
+	l.Changed = func(i int) (e duit.Event) {
+		v = NewNav()
+		render()
+		return b.GoHistory(l.Values[i].Value.(int))
+	}
+	return duit.NewKids(
+		&duit.Button{
+			Text: "Close",
+			Font: browser.Style.Font(),
+			Click: func() (e duit.Event) {
+				v = NewNav()
+				render()
+				e.Consumed = true
+				return
+			},
+		},
+		duit.NewScroll(l),
+	)
+}
+
+// SiteView toggles the permissions and zoom of the current site
+type SiteView struct {
+	u       *url.URL
+	js      *duit.Checkbox
+	images  *duit.Checkbox
+	cookies *duit.Checkbox
+}
+
+func NewSiteView() (s *SiteView) {
+	p := perm.Get(b.URL())
+	return &SiteView{
+		u:       b.URL(),
+		js:      &duit.Checkbox{Checked: p.JS},
+		images:  &duit.Checkbox{Checked: p.Images},
+		cookies: &duit.Checkbox{Checked: p.Cookies},
+	}
+}
+
+func (s *SiteView) Render() []*duit.Kid {
+	label := func(text string) *duit.Label {
+		return &duit.Label{
+			Text: text,
+			Font: browser.Style.Font(),
+		}
+	}
+	return duit.NewKids(
+		label("Settings of "+perm.Origin(s.u)),
+		&duit.Grid{
+			Columns: 2,
+			Padding: duit.NSpace(2, duit.SpaceXY(5, 3)),
+			Valign:  []duit.Valign{duit.ValignMiddle, duit.ValignMiddle},
+			Kids: duit.NewKids(
+				s.js, label("JavaScript"),
+				s.images, label("Images"),
+				s.cookies, label("Cookies"),
+			),
+		},
+		&duit.Grid{
+			Columns: 4,
+			Padding: duit.NSpace(4, duit.SpaceXY(5, 3)),
+			Valign:  []duit.Valign{duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle, duit.ValignMiddle},
+			Kids: duit.NewKids(
+				label(fmt.Sprintf("Zoom %v%%", math.Round(zoom.Get(s.u)*100))),
+				s.zoomButton("-", -1),
+				s.zoomButton("Reset", 0),
+				s.zoomButton("+", 1),
+			),
+		},
+		&duit.Grid{
+			Columns: 2,
+			Padding: duit.NSpace(2, duit.SpaceXY(5, 3)),
+			Kids: duit.NewKids(
+				&duit.Button{
+					Text: "Save and reload",
+					Font: browser.Style.Font(),
+					Click: func() (e duit.Event) {
+						p := perm.Perm{
+							JS:      s.js.Checked,
+							Images:  s.images.Checked,
+							Cookies: s.cookies.Checked,
+						}
+						if err := perm.Set(s.u, p); err != nil {
+							log.Errorf("set permissions: %v", err)
+						}
+						v = NewNav()
+						render()
+						return b.LoadUrl(b.URL())
+					},
+				},
+				&duit.Button{
+					Text: "Close",
+					Font: browser.Style.Font(),
+					Click: func() (e duit.Event) {
+						v = NewNav()
+						render()
+						e.Consumed = true
+						return
+					},
+				},
+			),
+		},
+	)
+}
+
+func (s *SiteView) zoomButton(text string, delta int) *duit.Button {
+	return &duit.Button{
+		Text: text,
+		Font: browser.Style.Font(),
+		Click: func() (e duit.Event) {
+			b.Zoom(delta)
+			render()
+			e.Consumed = true
+			return
+		},
+	}
+}
+
+// Menu with one button per item, the index of the chosen item
+// is sent to res
+type Menu struct {
+	items []string
+	res   chan int
+	done  bool
+}
+
+func (m *Menu) Render() []*duit.Kid {
+	choose := func(i int) func() duit.Event {
+		return func() (e duit.Event) {
+			if m.done {
+				return
+			}
+			if i >= 0 {
+				m.res <- i
+			} else {
+				close(m.res)
+			}
+			m.done = true
+			e.Consumed = true
+			v = NewNav()
+			render()
+			return
+		}
+	}
+	kids := make([]duit.UI, 0, len(m.items)+1)
+	for i, it := range m.items {
+		kids = append(kids, &duit.Button{
+			Text:  it,
+			Font:  browser.Style.Font(),
+			Click: choose(i),
+		})
+	}
+	kids = append(kids, &duit.Button{
+		Text:  "Cancel",
+		Font:  browser.Style.Font(),
+		Click: choose(-1),
+	})
+	return duit.NewKids(
+		&duit.Grid{
+			Columns: 1,
+			Padding: duit.NSpace(1, duit.SpaceXY(5, 3)),
+			Kids:    duit.NewKids(kids...),
+		},
+	)
+}
+
+// plumbed URLs from the web port are opened in a new tab
+func plumbed() {
+	err := plumber.Listen("web", func(s string) {
+		if !strings.Contains(s, "://") {
+			s = "http://" + s
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			log.Errorf("plumbed %v: %v", s, err)
+			return
+		}
+		dui.Call <- func() {
+			openTab(u)
+		}
+	})
+	log.Infof("plumber: %v", err)
+}
+
 type Confirm struct {
 	text  string
 	value string
@@ -168,13 +587,13 @@ func (c *Confirm) Render() []*duit.Kid {
 }
 
 func render() {
-	white, err := dui.Display.AllocImage(image.Rect(0, 0, 1, 1), draw.ARGB32, true, 0xffffffff)
+	bg, err := dui.Display.AllocImage(image.Rect(0, 0, 1, 1), draw.ARGB32, true, style.Background())
 	if err != nil {
 		log.Errorf("%v", err)
 	}
 	dui.Top.UI = &duit.Box{
 		Kids:       v.Render(),
-		Background: white,
+		Background: bg,
 	}
 	if b != nil {
 		browser.PrintTree(b.Website.UI)
@@ -186,61 +605,88 @@ func render() {
 	log.Printf("Rendering done")
 }
 
+// darkTheme for the widgets
+func darkTheme() {
+	c := func(c draw.Color) *draw.Image {
+		i, err := dui.Display.AllocImage(image.Rect(0, 0, 1, 1), draw.ARGB32, true, c)
+		if err != nil {
+			log.Fatalf("alloc: %v", err)
+		}
+		return i
+	}
+	dui.Regular = duit.Colorset{
+		Normal: duit.Colors{Text: c(0xddddddff), Background: c(0x2d2d2dff), Border: c(0x555555ff)},
+		Hover:  duit.Colors{Text: c(0xffffffff), Background: c(0x3a3a3aff), Border: c(0x3272dcff)},
+	}
+	dui.Disabled = duit.Colors{Text: c(0x777777ff), Background: c(0x262626ff), Border: c(0x444444ff)}
+	dui.Placeholder = duit.Colors{Text: c(0x888888ff), Background: c(0x2d2d2dff), Border: c(0x555555ff)}
+	dui.Striped = duit.Colors{Text: c(0xddddddff), Background: c(0x262626ff), Border: c(0x555555ff)}
+	dui.BackgroundColor = style.Background()
+	dui.ScrollBGNormal = c(0x262626ff)
+	dui.ScrollBGHover = c(0x2d2d2dff)
+	dui.ScrollVisibleNormal = c(0x555555ff)
+	dui.ScrollVisibleHover = c(0x777777ff)
+	dui.Gutter = c(0x555555ff)
+}
+
 func Main() (err error) {
 	dui, err = duit.NewDUI("mycel", nil) // TODO: rm global var
 	if err != nil {
 		return fmt.Errorf("new dui: %w", err)
 	}
 	dui.Debug = dbg
+	if style.Dark {
+		darkTheme()
+	}
 	resize()
 
 	style.Init(dui)
 	v = NewNav()
 	render()
 
-	b = &browser.Browser{
-		LocCh: make(chan string, 10),
+	switchTab(addTab(browser.NewBrowser(dui, loc)))
+	for _, t := range b.RestoreTabs() {
+		addTab(t)
 	}
-	b = browser.NewBrowser(dui, loc)
-	b.Download = func(res chan *string) {
-		v = &Confirm{
-			text:  fmt.Sprintf("Download %v", b.URL()),
-			value: "/download.file",
-			res:   res,
-		}
-		render()
-		return
-	}
-	v = NewNav()
-	render()
+	go plumbed()
 
 	for {
 		select {
 		case e := <-dui.Inputs:
 			//log.Infof("e=%v", e)
+			if nav, ok := v.(*Nav); ok && e.Type == duit.InputKey && e.Key == draw.KeyCmd+'f' {
+				nav.openFind()
+				continue
+			}
 			dui.Input(e)
 			if e.Type == duit.InputResize {
 				resize()
 			}
 
-		case loc = <-b.LocCh:
-			log.Infof("loc=%v", loc)
-			ue, err := url.QueryUnescape(loc)
+		case m := <-tabLocs:
+			log.Infof("loc=%v", m.s)
+			ue, err := url.QueryUnescape(m.s)
 			if err == nil {
-				loc = ue
+				m.t.loc = ue
 			} else {
-				log.Errorf("unescape %v: %v", loc, err)
+				m.t.loc = m.s
+				log.Errorf("unescape %v: %v", m.s, err)
+			}
+			if m.t != cur {
+				continue
 			}
+			loc = m.t.loc
 			if nav, ok := v.(*Nav); ok {
 				nav.LocationField.Text = loc
+				render()
 			}
 
-		case msg := <-b.StatusCh:
-			if nav, ok := v.(*Nav); ok {
-				if msg == "" {
+		case m := <-tabStats:
+			if nav, ok := v.(*Nav); ok && m.t == cur {
+				if m.s == "" {
 					nav.StatusBar.Text = ""
 				} else {
-					nav.StatusBar.Text += msg + "\n"
+					nav.StatusBar.Text += m.s + "\n"
 				}
 				dui.MarkLayout(nav.StatusBar)
 				dui.MarkDraw(nav.StatusBar)
@@ -259,7 +705,13 @@ func Main() (err error) {
 }
 
 func usage() {
-	fmt.Printf("usage: mycel [-v|-vv] [-h] [-jsinsecure] [-cpu|-mem fn] [startPage]\n")
+	fmt.Printf("usage: mycel [-v|-vv] [-h] [-jsinsecure] [-webfs] [-dark [-invert]] [-cpu|-mem fn] [-headless -o out.png [-w width]] [-dump [-cols n]] [startPage]\n")
+	fmt.Printf("\nkeys:\n")
+	fmt.Printf("  Tab, Cmd-Tab        focus next/previous link or form field\n")
+	fmt.Printf("                      (Cmd-Tab because draw devices don't report Shift-Tab)\n")
+	fmt.Printf("  Enter               follow the focused link\n")
+	fmt.Printf("  f                   link hints, Esc cancels\n")
+	fmt.Printf("  Cmd-+, Cmd--, Cmd-0 zoom in, out, reset\n")
 	os.Exit(1)
 }
 
@@ -279,12 +731,45 @@ func main() {
 			usage()
 			args = args[1:]
 		case "-jsinsecure":
-			browser.ExperimentalJsInsecure = true
+			perm.Default.JS = true
+			args = args[1:]
+		case "-webfs":
+			if !webfs.Available {
+				fmt.Fprintf(os.Stderr, "-webfs is only available on Plan 9\n")
+				os.Exit(1)
+			}
+			browser.Webfs = true
+			args = args[1:]
+		case "-dark":
+			style.SetDark(true, style.AutoInvert)
+			args = args[1:]
+		case "-invert":
+			style.SetDark(style.Dark, true)
 			args = args[1:]
 		case "-cpu":
 			cpuprofile, args = args[1], args[2:]
 		case "-mem":
 			memprofile, args = args[1], args[2:]
+		case "-dump":
+			dump = true
+			args = args[1:]
+		case "-cols":
+			c, err := strconv.Atoi(args[1])
+			if err != nil || c <= 0 {
+				usage()
+			}
+			cols, args = c, args[2:]
+		case "-headless":
+			headless = true
+			args = args[1:]
+		case "-o":
+			out, args = args[1], args[2:]
+		case "-w":
+			w, err := strconv.Atoi(args[1])
+			if err != nil || w <= 0 {
+				usage()
+			}
+			width, args = w, args[2:]
 		default:
 			if len(args) > 1 {
 				usage()
@@ -335,6 +820,33 @@ func main() {
 		finalize()
 	}()
 
+	if (dump || headless) && loc == "" {
+		usage()
+	}
+
+	if dump {
+		u, err := browser.ParseLocation(loc)
+		if err != nil {
+			log.Fatalf("parse url: %v", err)
+		}
+		if err := browser.Dump(os.Stdout, u, cols); err != nil {
+			log.Fatalf("Dump: %v", err)
+		}
+		os.Exit(0)
+	}
+
+	if headless {
+		if out == "" {
+			usage()
+		}
+		err := Headless()
+		js.StopAll()
+		if err != nil {
+			log.Fatalf("Headless: %v", err)
+		}
+		os.Exit(0)
+	}
+
 	if err := Main(); err != nil {
 		log.Fatalf("Main: %v", err)
 	}
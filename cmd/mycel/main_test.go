@@ -1 +1,78 @@
 package main
+
+import (
+	"github.com/psilva261/mycel/js"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func decodePNG(t *testing.T, fn string) image.Image {
+	f, err := os.Open(fn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	i, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decode %v: %v", fn, err)
+	}
+	return i
+}
+
+func TestHeadless(t *testing.T) {
+	if runtime.GOOS == "plan9" {
+		t.Skip("needs a window on plan9")
+	}
+	if _, err := exec.LookPath("devdraw"); err != nil {
+		t.Skip("needs devdraw")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	// the saved session is left alone
+	conf := filepath.Join(home, ".config", "mycel")
+	session := "0\nhttps://example.com 0\n"
+	if err := os.MkdirAll(conf, 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(conf, "history"), []byte(session), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	fn, err := filepath.Abs("testdata/headless.html")
+	if err != nil {
+		t.Fatalf("abs: %v", err)
+	}
+	loc = fn
+	out = filepath.Join(t.TempDir(), "out.png")
+	width = 400
+	err = Headless()
+	js.StopAll()
+	if err != nil {
+		t.Fatalf("headless: %v", err)
+	}
+	if buf, err := os.ReadFile(filepath.Join(conf, "history")); err != nil || string(buf) != session {
+		t.Fatalf("session changed: %q %v", buf, err)
+	}
+	if fis, _ := os.ReadDir(conf); len(fis) != 1 {
+		t.Fatalf("profile changed: %v", fis)
+	}
+
+	i := decodePNG(t, out)
+	if w := i.Bounds().Dx(); w != dui.Scale(width) {
+		t.Fatalf("width %v", w)
+	}
+	// background of the .box div
+	for y := i.Bounds().Min.Y; y < i.Bounds().Max.Y; y++ {
+		for x := i.Bounds().Min.X; x < i.Bounds().Max.X; x++ {
+			if r, g, b, _ := i.At(x, y).RGBA(); r>>8 == 0 && g>>8 == 0x88 && b>>8 == 0xff {
+				return
+			}
+		}
+	}
+	t.Fatalf("box not drawn")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/mycel/browser"
+	"github.com/psilva261/mycel/logger"
+	"net/url"
+	"os"
+)
+
+// maxTabTitle is the number of characters shown on tab buttons
+const maxTabTitle = 20
+
+type Tab struct {
+	*browser.Browser
+	loc  string
+	done chan struct{}
+}
+
+type tabMsg struct {
+	t *Tab
+	s string
+}
+
+var (
+	tabs     []*Tab
+	cur      *Tab
+	tabLocs  = make(chan tabMsg, 10)
+	tabStats = make(chan tabMsg, 10)
+)
+
+// addTab and forward its location and status updates to the main loop
+func addTab(br *browser.Browser) (t *Tab) {
+	t = &Tab{
+		Browser: br,
+		done:    make(chan struct{}),
+	}
+	t.Download = func(u *url.URL, fn string, res chan *string) {
+		v = &Confirm{
+			text:  fmt.Sprintf("Download %v", u),
+			value: fn,
+			res:   res,
+		}
+		render()
+	}
+	t.PickFile = func(res chan *string) {
+		home, _ := os.UserHomeDir()
+		v = &Confirm{
+			text:  "Upload file",
+			value: home,
+			res:   res,
+		}
+		render()
+	}
+	t.OpenTab = openTab
+	t.FindText = func(q string) int {
+		if nav, ok := v.(*Nav); ok && t == cur {
+			return nav.find(q)
+		}
+		return t.Find(q)
+	}
+	t.Menu = func(items []string, res chan int) {
+		v = &Menu{
+			items: items,
+			res:   res,
+		}
+		render()
+	}
+	tabs = append(tabs, t)
+	go func() {
+		for {
+			select {
+			case l := <-t.LocCh:
+				tabLocs <- tabMsg{t, l}
+			case s := <-t.StatusCh:
+				tabStats <- tabMsg{t, s}
+			case <-t.done:
+				return
+			}
+		}
+	}()
+	return
+}
+
+func switchTab(t *Tab) {
+	cur = t
+	b = t.Browser
+	loc = t.loc
+	b.Activate()
+	v = NewNav()
+	render()
+}
+
+// openTab loads u in a new tab and switches to it
+func openTab(u *url.URL) {
+	switchTab(addTab(b.NewTab(u)))
+}
+
+func closeTab(t *Tab) {
+	if len(tabs) == 1 {
+		return
+	}
+	for i, tt := range tabs {
+		if tt != t {
+			continue
+		}
+		tabs = append(tabs[:i], tabs[i+1:]...)
+		if i == len(tabs) {
+			i--
+		}
+		close(t.done)
+		t.Close()
+		if t == cur {
+			switchTab(tabs[i])
+		}
+		return
+	}
+}
+
+func (t *Tab) title() string {
+	s := t.loc
+	if u, err := url.Parse(t.loc); err == nil && u.Host != "" {
+		s = u.Host
+	}
+	if r := []rune(s); len(r) > maxTabTitle {
+		s = string(r[:maxTabTitle-1]) + "…"
+	}
+	if s == "" {
+		s = "New tab"
+	}
+	return s
+}
+
+// tabBar with one button per tab and buttons to open or close a tab
+func tabBar() duit.UI {
+	kids := make([]duit.UI, 0, len(tabs)+2)
+	for _, t := range tabs {
+		t := t
+		btn := &duit.Button{
+			Text: t.title(),
+			Font: browser.Style.Font(),
+			Click: func() (e duit.Event) {
+				if t != cur {
+					switchTab(t)
+				}
+				e.Consumed = true
+				return
+			},
+		}
+		if t == cur {
+			btn.Colorset = &dui.Primary
+		}
+		kids = append(kids, btn)
+	}
+	kids = append(kids,
+		&duit.Button{
+			Text: "+",
+			Font: browser.Style.Font(),
+			Click: func() (e duit.Event) {
+				u, err := url.Parse(browser.DefaultUrl)
+				if err != nil {
+					log.Errorf("parse: %v", err)
+					return
+				}
+				openTab(u)
+				e.Consumed = true
+				return
+			},
+		},
+		&duit.Button{
+			Text: "Close tab",
+			Font: browser.Style.Font(),
+			Click: func() (e duit.Event) {
+				closeTab(cur)
+				e.Consumed = true
+				return
+			},
+		},
+	)
+	return &duit.Box{
+		Kids: duit.NewKids(kids...),
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/mjl-/duit"
 	"github.com/psilva261/mycel"
+	"github.com/psilva261/mycel/browser/block"
 	"github.com/psilva261/mycel/logger"
 	"github.com/srwiley/oksvg"
 	"github.com/srwiley/rasterx"
@@ -152,9 +153,9 @@ func quoteAttrs(s string) string {
 }
 
 // Svg returns the svg+xml with the sizing defined in
-// viewbox unless w and h != 0
-func Svg(dui *duit.DUI, data string, w, h int) (ni *draw.Image, err error) {
-	rgba, err := svg(data, w, h)
+// viewbox multiplied by zoom unless w and h != 0
+func Svg(dui *duit.DUI, data string, w, h int, zoom float64) (ni *draw.Image, err error) {
+	rgba, err := svg(data, w, h, zoom)
 	if err != nil {
 		return nil, err
 	}
@@ -169,7 +170,7 @@ func Svg(dui *duit.DUI, data string, w, h int) (ni *draw.Image, err error) {
 	return
 }
 
-func svg(data string, w, h int) (img *image.RGBA, err error) {
+func svg(data string, w, h int, zoom float64) (img *image.RGBA, err error) {
 	data = strings.ReplaceAll(data, "currentColor", "black")
 	data = strings.ReplaceAll(data, "inherit", "black")
 	data = quoteAttrs(data)
@@ -181,8 +182,8 @@ func svg(data string, w, h int) (img *image.RGBA, err error) {
 	}
 
 	if w == 0 || h == 0 {
-		w = int(icon.ViewBox.W)
-		h = int(icon.ViewBox.H)
+		w = int(icon.ViewBox.W * zoom)
+		h = int(icon.ViewBox.H * zoom)
 	}
 
 	icon.SetTarget(0, 0, float64(w), float64(h))
@@ -192,16 +193,17 @@ func svg(data string, w, h int) (img *image.RGBA, err error) {
 	return rgba, nil
 }
 
-// Load and resize to w and h if != 0
-func Load(dui *duit.DUI, f mycel.Fetcher, src string, maxW, w, h int, forceSync bool) (ni *draw.Image, err error) {
-	log.Printf("Load(..., %v, maxW=%v, w=%v, h=%v, ...)", src, maxW, w, h)
+// Load and resize to w and h if != 0, otherwise the natural size is
+// multiplied by zoom
+func Load(dui *duit.DUI, f mycel.Fetcher, src string, maxW, w, h int, zoom float64, forceSync bool) (ni *draw.Image, err error) {
+	log.Printf("Load(..., %v, maxW=%v, w=%v, h=%v, zoom=%v, ...)", src, maxW, w, h, zoom)
 	ch := make(chan image.Image, 1)
 	var bounds draw.Rectangle
 	if w != 0 && h != 0 && !forceSync {
 		bounds = draw.Rect(0, 0, w, h)
 		go func() {
 			log.Printf("load async %v...", src)
-			drawImg, err := load(f, src, maxW, w, h)
+			drawImg, err := load(f, src, maxW, w, h, zoom)
 			if err != nil {
 				log.Errorf("load %v: %v", src, err)
 				close(ch)
@@ -211,7 +213,7 @@ func Load(dui *duit.DUI, f mycel.Fetcher, src string, maxW, w, h int, forceSync
 			log.Printf("loaded async %v", src)
 		}()
 	} else {
-		drawImg, err := load(f, src, maxW, w, h)
+		drawImg, err := load(f, src, maxW, w, h, zoom)
 		if err != nil {
 			return nil, err
 		}
@@ -251,7 +253,21 @@ func Load(dui *duit.DUI, f mycel.Fetcher, src string, maxW, w, h int, forceSync
 	return
 }
 
-func load(f mycel.Fetcher, src string, maxW, w, h int) (img image.Image, err error) {
+// imageGetter is implemented by Fetchers that can be told the
+// resource type
+type imageGetter interface {
+	GetAs(*url.URL, block.Type) ([]byte, mycel.ContentType, error)
+}
+
+// get the image at u, as image also without file extension
+func get(f mycel.Fetcher, u *url.URL) ([]byte, mycel.ContentType, error) {
+	if g, ok := f.(imageGetter); ok {
+		return g.GetAs(u, block.Image)
+	}
+	return f.Get(u)
+}
+
+func load(f mycel.Fetcher, src string, maxW, w, h int, zoom float64) (img image.Image, err error) {
 	var imgUrl *url.URL
 	var data []byte
 	var contentType mycel.ContentType
@@ -264,13 +280,13 @@ func load(f mycel.Fetcher, src string, maxW, w, h int) (img image.Image, err err
 		if imgUrl, err = f.LinkedUrl(src); err != nil {
 			return nil, err
 		}
-		if data, contentType, err = f.Get(imgUrl); err != nil {
+		if data, contentType, err = get(f, imgUrl); err != nil {
 			return nil, fmt.Errorf("get %v: %w", imgUrl, err)
 		}
 	}
 
 	if contentType.IsSvg() {
-		img, err := svg(string(data), w, h)
+		img, err := svg(string(data), w, h, zoom)
 		if err != nil {
 			return nil, fmt.Errorf("svg: %v", err)
 		}
@@ -280,12 +296,15 @@ func load(f mycel.Fetcher, src string, maxW, w, h int) (img image.Image, err err
 		if err != nil {
 			return nil, fmt.Errorf("decode %v: %w", imgUrl, err)
 		}
-		if maxW != 0 || w != 0 || h != 0 {
+		if maxW != 0 || w != 0 || h != 0 || zoom != 1 {
 			dx := img.Bounds().Max.X
 			dy := img.Bounds().Max.Y
 			log.Printf("dx,dy=%v,%v", dx, dy)
-			if w == 0 && h == 0 && 0 < maxW && maxW < dx {
-				w = maxW
+			if w == 0 && h == 0 {
+				w = int(float64(dx) * zoom)
+				if 0 < maxW && maxW < w {
+					w = maxW
+				}
 			}
 
 			newX, newY, skip := newSizes(dx, dy, w, h)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"github.com/psilva261/mycel"
+	"github.com/psilva261/mycel/browser/block"
 	"github.com/psilva261/mycel/logger"
 	"image"
 	"image/png"
@@ -56,7 +57,7 @@ func TestSvg(t *testing.T) {
 	}
 
 	for _, xml := range xmls {
-		_, err := svg(xml, 0, 0)
+		_, err := svg(xml, 0, 0, 1)
 		if err != nil {
 			t.Fatalf(err.Error())
 		}
@@ -72,7 +73,7 @@ func TestSvgUnquoted(t *testing.T) {
        `
 	xml = `<svg xmlns=http://www.w3.org/2000/svg viewBox=0 0 37 37 fill=#000000><path class=border fill=blue stroke=green/></svg>`
 
-	_, err := svg(xml, 0, 0)
+	_, err := svg(xml, 0, 0, 1)
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -108,6 +109,30 @@ func (b *MockBrowser) Get(*url.URL) ([]byte, mycel.ContentType, error) {
 	return b.data, mycel.ContentType{}, nil
 }
 
+type typedMock struct {
+	MockBrowser
+	t block.Type
+}
+
+func (b *typedMock) GetAs(u *url.URL, t block.Type) ([]byte, mycel.ContentType, error) {
+	b.t = t
+	return b.Get(u)
+}
+
+func TestLoadType(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	b := &typedMock{MockBrowser: MockBrowser{buf.Bytes()}}
+	if _, err := load(b, "/avatar", 0, 0, 0, 1); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if b.t != block.Image {
+		t.Fatalf("%v", b.t)
+	}
+}
+
 func TestLoad(t *testing.T) {
 	rows := [][]int{
 		{1700, 0, 0, 1600, 900},
@@ -125,7 +150,7 @@ func TestLoad(t *testing.T) {
 			t.Fail()
 		}
 		b := &MockBrowser{buf.Bytes()}
-		img, err := load(b, "", mw, w, h)
+		img, err := load(b, "", mw, w, h, 1)
 		if err != nil {
 			t.Errorf("load: %v", err)
 		}
@@ -137,6 +162,32 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestLoadZoom(t *testing.T) {
+	rows := []struct {
+		mw         int
+		zoom       float64
+		xNew, yNew int
+	}{
+		{0, 0.5, 800, 450},
+		{1000, 2, 1000, 562},
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 1600, 900))
+	buf := bytes.NewBufferString("")
+	if err := png.Encode(buf, dst); err != nil {
+		t.Fatalf("%v", err)
+	}
+	b := &MockBrowser{buf.Bytes()}
+	for _, r := range rows {
+		img, err := load(b, "", r.mw, 0, 0, r.zoom)
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if s := img.Bounds().Size(); s.X != r.xNew || s.Y != r.yNew {
+			t.Errorf("%+v: unexpected size %v", r, s)
+		}
+	}
+}
+
 func TestNewSizes(t *testing.T) {
 	x0 := 400
 	y0 := 300
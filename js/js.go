@@ -1,6 +1,8 @@
 package js
 
 import (
+	"9fans.net/go/plan9"
+	"9fans.net/go/plan9/client"
 	"bufio"
 	"bytes"
 	"context"
@@ -14,6 +16,7 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,14 +24,55 @@ var timeout = 60 * time.Second
 
 var (
 	instances sync.Map
+	started   atomic.Int64
 )
 
 type JS struct {
+	sparkleConn
 	service string
 	cmd *exec.Cmd
 	cancel  context.CancelFunc
 }
 
+type sparkleConn struct {
+	conn *client.Conn
+	fsys *client.Fsys
+}
+
+func (js *JS) dial() (err error) {
+	log.Infof("Init...")
+	js.conn, err = dialService(js.service)
+	if err != nil {
+		return fmt.Errorf("dial: %v", err)
+	}
+	un, err := username()
+	if err != nil {
+		return
+	}
+	js.fsys, err = js.conn.Attach(nil, un, "")
+	if err != nil {
+		return fmt.Errorf("attach: %v", err)
+	}
+	return
+}
+
+func (js *JS) hangup() {
+	js.fsys = nil
+	if js.conn != nil {
+		js.conn.Close()
+		js.conn = nil
+	}
+}
+
+func (js *JS) callSparkleCtl() (rwc io.ReadWriteCloser, err error) {
+	if js.fsys == nil {
+		if err := js.dial(); err != nil {
+			return nil, fmt.Errorf("dial: %v", err)
+		}
+	}
+	return js.fsys.Open("ctl", plan9.ORDWR)
+}
+
 func (js *JS) call(fn, cmd string, args ...string) (resp string, err error) {
 	var rwc io.ReadWriteCloser
 	for t := 100*time.Millisecond; t < 5*time.Second; t *= 2 {
@@ -58,7 +102,7 @@ func (js *JS) call(fn, cmd string, args ...string) (resp string, err error) {
 // Start with pre-defined scripts
 func Start(f mycel.Fetcher, scripts ...string) (js *JS, resHtm string, changed bool, err error) {
 	js = &JS{
-		service: fmt.Sprintf("sparkle.%d", os.Getpid()),
+		service: fmt.Sprintf("sparkle.%d.%d", os.Getpid(), started.Add(1)),
 	}
 	args := make([]string, 0, len(scripts)+2)
 	if log.Debug {
@@ -102,6 +146,9 @@ func StopAll() {
 }
 
 func (js *JS) Stop() {
+	if js == nil {
+		return
+	}
 	log.Infof("Stop sparklefs")
 	js.hangup()
 	if js.cancel != nil {
@@ -130,7 +177,7 @@ func Srcs(doc *nodes.Node) (srcs []string) {
 	srcs = make([]string, 0, 3)
 
 	iterateJsElements(doc, func(src, inlineCode string) {
-		if src = strings.TrimSpace(src); src != "" && !blocked(src) {
+		if src = strings.TrimSpace(src); src != "" {
 			srcs = append(srcs, src)
 		}
 	})
@@ -138,28 +185,6 @@ func Srcs(doc *nodes.Node) (srcs []string) {
 	return
 }
 
-func blocked(src string) bool {
-	for _, s := range []string{
-		"adsense",
-		"adsystem",
-		"adservice",
-		"googletagservice",
-		"googletagmanager",
-		"script.ioam.de",
-		"googlesyndication",
-		"adserver",
-		"nativeads",
-		"prebid",
-		".ads.",
-		"google-analytics.com",
-	} {
-		if strings.Contains(src, s) {
-			return true
-		}
-	}
-	return false
-}
-
 func Scripts(doc *nodes.Node, downloads map[string]string) (codes []string) {
 	codes = make([]string, 0, 3)
 
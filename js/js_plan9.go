@@ -1,12 +1,25 @@
 package js
 
 import (
-	"io"
+	"9fans.net/go/plan9/client"
 	"os"
+	"strings"
 )
 
-func (js *JS) hangup() {}
+// dialService opens the channel sparklefs posted in /srv, so that
+// every tab talks to its own instance
+func dialService(service string) (*client.Conn, error) {
+	f, err := os.OpenFile("/srv/"+service, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewConn(f)
+}
 
-func (js *JS) callSparkleCtl() (rwc io.ReadWriteCloser, err error) {
-	return os.OpenFile("/mnt/sparkle/ctl", os.O_RDWR, 0600)
+func username() (string, error) {
+	b, err := os.ReadFile("/dev/user")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
 }
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"mime"
 	"net/url"
+	"os"
 	"strings"
 )
 
@@ -23,6 +24,16 @@ type Fetcher interface {
 	Get(*url.URL) ([]byte, ContentType, error)
 }
 
+// ConfigDir is where user settings are stored, e.g.
+// $home/lib/mycel on Plan 9 or ~/.config/mycel on Unix.
+func ConfigDir() (string, error) {
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return d + "/mycel", nil
+}
+
 type ContentType struct {
 	MediaType string
 	Params    map[string]string
@@ -40,6 +51,16 @@ func NewContentType(s string, u *url.URL) (c ContentType, err error) {
 			return NewContentType("image/png", u)
 		case "gif":
 			return NewContentType("image/gif", u)
+		case "svg":
+			return NewContentType("image/svg+xml", u)
+		case "html", "htm":
+			return NewContentType("text/html", u)
+		case "css":
+			return NewContentType("text/css", u)
+		case "js":
+			return NewContentType("application/javascript", u)
+		case "txt":
+			return NewContentType("text/plain", u)
 		default:
 			return ContentType{}, nil
 		}
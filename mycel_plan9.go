@@ -9,3 +9,11 @@ const PathPrefix = "/mnt/mycel"
 func Group(u *user.User) (string, error) {
 	return u.Gid, nil
 }
+
+func CacheDir() (string, error) {
+	d, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return d + "/cache", nil
+}
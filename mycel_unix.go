@@ -4,6 +4,7 @@ package mycel
 
 import (
 	"fmt"
+	"os"
 	"os/user"
 )
 
@@ -16,3 +17,11 @@ func Group(u *user.User) (string, error) {
 	}
 	return g.Name, nil
 }
+
+func CacheDir() (string, error) {
+	d, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return d + "/mycel", nil
+}
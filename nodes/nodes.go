@@ -17,7 +17,8 @@ type Node struct {
 	style.Map
 	Rectangular
 	Children []*Node
-	parent   *Node `json:"-"`
+	parent   *Node       `json:"-"`
+	page     *style.Page `json:"-"`
 }
 
 type Rectangular interface {
@@ -109,6 +110,18 @@ func (n *Node) Style() style.Map {
 	return n.Map
 }
 
+// Page the node tree belongs to
+func (n *Node) Page() *style.Page {
+	return n.page
+}
+
+// SetPage of n and its descendants
+func (n *Node) SetPage(p *style.Page) {
+	n.Traverse(func(_ int, c *Node) {
+		c.page = p
+	})
+}
+
 func (n *Node) Rect() image.Rectangle {
 	if n.Rectangular == nil {
 		log.Errorf("rectangular nil")
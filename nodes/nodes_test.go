@@ -261,3 +261,65 @@ func TestCBItems(t *testing.T) {
 		}
 	}
 }
+
+func TestArticle(t *testing.T) {
+	p := "<p>Plan 9 from Bell Labs is a distributed operating system, originally developed at Bell Labs, that represents all resources as files.</p>"
+	buf := strings.NewReader(`
+	<html>
+		<body>
+			<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+			<div class="sidebar">
+				<p>Related: <a href="/a">A long link title about something else entirely</a></p>
+			</div>
+			<div id="main-content">
+				<h1>Plan 9</h1>
+				` + p + p + p + `
+			</div>
+			<div class="footer"><p>Copyright, all rights reserved, no warranty given at all.</p></div>
+		</body>
+	</html>`)
+	doc, err := html.Parse(buf)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	nt := NewNodeTree(doc, style.Map{}, make(map[*html.Node]style.Map), nil)
+	a := nt.Article()
+	if a == nil || a.Attr("id") != "main-content" {
+		t.Fatalf("%+v", a)
+	}
+	nt = NewNodeTree(doc, style.Map{}, make(map[*html.Node]style.Map), nil).Find("nav")
+	if a := nt.Article(); a != nil {
+		t.Fatalf("%+v", a)
+	}
+}
+
+func TestUserCSSInherited(t *testing.T) {
+	for _, tt := range []struct {
+		page, user, htm, want string
+	}{
+		{"body { color: #888888; }", "p { color: #000000; }", `<p>a</p>`, "#000000"},
+		{"", "body { color: red !important; }", `<p style="color: blue">a</p>`, "blue"},
+	} {
+		doc, err := html.Parse(strings.NewReader(tt.htm))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		nm, err := style.FetchNodeMap(doc, tt.page)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		um, err := style.FetchUserNodeMap(doc, tt.user)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		style.MergeNodeMaps(nm, um)
+		nt := NewNodeTree(doc, style.Map{}, nm, nil)
+		ps, err := nt.Query("p")
+		if err != nil || len(ps) != 1 {
+			t.Fatalf("%v %v", ps, err)
+		}
+		if c := ps[0].Css("color"); c != tt.want {
+			t.Errorf("%+v: %v", tt, c)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package nodes
+
+import (
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+)
+
+var (
+	rePositive = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	reNegative = regexp.MustCompile(`(?i)comment|footer|sidebar|nav|menu|header|banner|share|social|related|widget|promo|sponsor|(^|[-_ ])ads?($|[-_ ])`)
+)
+
+// unlikely elements are skipped with their children
+var unlikely = map[string]bool{
+	"aside":    true,
+	"button":   true,
+	"footer":   true,
+	"form":     true,
+	"header":   true,
+	"iframe":   true,
+	"nav":      true,
+	"noscript": true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+}
+
+// minArticleLen of the text for a node to be taken as article
+const minArticleLen = 200
+
+// Article returns the node with the main content or nil if there is
+// none. Like readability, paragraphs add to the score of their parent
+// and half of it to the grandparent based on text length and commas.
+// Tag names and class or id names are weighted and the score is
+// finally reduced by the link density.
+func (n *Node) Article() (best *Node) {
+	var cands []*Node
+	scores := make(map[*Node]float64)
+	add := func(c *Node, s float64) {
+		if c == nil || c.DomSubtree == nil {
+			return
+		}
+		if _, ok := scores[c]; !ok {
+			cands = append(cands, c)
+			scores[c] = weight(c)
+		}
+		scores[c] += s
+	}
+	n.scoreParagraphs(func(p *Node, s float64) {
+		add(p.parent, s)
+		if p.parent != nil {
+			add(p.parent.parent, s/2)
+		}
+	})
+	var max float64
+	for _, c := range cands {
+		s := scores[c] * (1 - c.linkDensity())
+		if best == nil || s > max {
+			best, max = c, s
+		}
+	}
+	if best == nil || len(best.ContentString(false)) < minArticleLen {
+		return nil
+	}
+	return
+}
+
+// scoreParagraphs of n and its children
+func (n *Node) scoreParagraphs(f func(p *Node, s float64)) {
+	switch {
+	case n.Type() == html.DocumentNode:
+	case n.Type() != html.ElementNode, unlikely[n.Data()], n.Map.IsDisplayNone():
+		return
+	}
+	if n.isParagraph() {
+		if t := n.ContentString(false); len(t) >= 25 {
+			f(n, 1+float64(strings.Count(t, ","))+min(float64(len(t))/100, 3))
+		}
+	}
+	for _, c := range n.Children {
+		c.scoreParagraphs(f)
+	}
+}
+
+// isParagraph is true for p and pre elements and divs or table
+// cells without block children
+func (n *Node) isParagraph() bool {
+	switch n.Data() {
+	case "p", "pre":
+		return true
+	case "div", "td":
+		for _, c := range n.Children {
+			switch c.Data() {
+			case "blockquote", "div", "dl", "h1", "h2", "h3", "h4", "h5", "h6", "ol", "p", "pre", "section", "table", "ul":
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// weight of n based on the tag name and the class and id names
+func weight(n *Node) (w float64) {
+	switch n.Data() {
+	case "article", "main":
+		w = 10
+	case "div":
+		w = 5
+	case "blockquote", "pre", "td":
+		w = 3
+	case "address", "dd", "dl", "dt", "li", "ol", "ul":
+		w = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		w = -5
+	}
+	for _, a := range []string{n.Attr("class"), n.Attr("id")} {
+		if a == "" {
+			continue
+		}
+		if reNegative.MatchString(a) {
+			w -= 25
+		}
+		if rePositive.MatchString(a) {
+			w += 25
+		}
+	}
+	return
+}
+
+// linkDensity is the share of text within links
+func (n *Node) linkDensity() float64 {
+	l := len(n.ContentString(false))
+	if l == 0 {
+		return 0
+	}
+	var ll int
+	for _, a := range n.FindAll("a") {
+		ll += len(a.ContentString(false))
+	}
+	return float64(ll) / float64(l)
+}
@@ -33,6 +33,8 @@ type Selector struct {
 
 type Declaration struct {
 	Important   bool
+	User        bool // from a user stylesheet
+	inherited   bool // from an ancestor, not the element itself
 	Specificity cascadia.Specificity
 	Prop        string
 	Val         string
@@ -59,20 +59,14 @@ func (cs Map) BoxBackground() (i *draw.Image, err error) {
 
 func (cs Map) backgroundColor() (c draw.Color, ok bool) {
 	d, ok := cs.Declarations["background-color"]
-	if ok {
-		c, ok = colorHex(d.Val)
-		if !ok {
-			return
-		}
-		return c, true
+	if !ok {
+		d, ok = cs.Declarations["background"]
 	}
-	d, ok = cs.Declarations["background"]
-	if ok {
-		c, ok = colorHex(d.Val)
-		if !ok {
-			return
-		}
-		return c, true
+	if !ok {
+		return
+	}
+	if c, ok = colorHex(d.Val); ok && cs.inverted() {
+		c = invert(c)
 	}
 	return
 }
@@ -194,7 +188,7 @@ func (cs Map) backgroundImage() (i *draw.Image) {
 		h := cs.Height()
 
 		var err error
-		i, err = img.Load(dui, fetcher, imgUrl, 0, w, h, true)
+		i, err = img.Load(dui, fetcher, imgUrl, 0, w, h, cs.Zoom(), true)
 		if err != nil {
 			log.Errorf("bg img load %v: %v", imgUrl, err)
 			return
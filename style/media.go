@@ -47,6 +47,7 @@ func MatchQuery(mediaQuery string, values map[string]string) (yes bool, err erro
 		}
 
 		every := true
+	exprs:
 		for _, expr := range q.exprs {
 			var valueFloat float64
 			var expValueFloat float64
@@ -63,8 +64,9 @@ func MatchQuery(mediaQuery string, values map[string]string) (yes bool, err erro
 			case "orientation", "scan", "prefers-color-scheme":
 				if strings.ToLower(value) != strings.ToLower(expValue) {
 					every = false
-					break
+					break exprs
 				}
+				continue
 			case "width", "height", "device-width", "device-height":
 				if expValueFloat, err = toPx(expValue); err != nil {
 					break
@@ -109,6 +111,9 @@ func MatchQuery(mediaQuery string, values map[string]string) (yes bool, err erro
 			default:
 				every = valueFloat == expValueFloat
 			}
+			if !every {
+				break
+			}
 		}
 		if (every && !inverse) || (!every && inverse) {
 			return true, nil
@@ -148,7 +153,7 @@ func parseQuery(mediaQuery string) (tokens []MediaQuery, err error) {
 			continue
 		}
 
-		exprsList := reExpressions.FindStringSubmatch(exprs)
+		exprsList := reExpressions.FindAllString(exprs, -1)
 		if exprsList == nil {
 			return tokens, fmt.Errorf("Invalid CSS media query: %v", q)
 		}
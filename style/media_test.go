@@ -48,3 +48,22 @@ func TestMatchQuery(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestMatchQueryColorScheme(t *testing.T) {
+	values := map[string]string{
+		"type":                 "screen",
+		"width":                "500",
+		"prefers-color-scheme": "light",
+	}
+	for q, exp := range map[string]bool{
+		`(prefers-color-scheme: dark)`:                                    false,
+		`(prefers-color-scheme: light)`:                                   true,
+		`screen and (prefers-color-scheme: dark) and (max-width: 600px)`:  false,
+		`screen and (max-width: 600px) and (prefers-color-scheme: dark)`:  false,
+		`screen and (max-width: 400px) and (prefers-color-scheme: light)`: false,
+	} {
+		if yes, err := MatchQuery(q, values); err != nil || yes != exp {
+			t.Errorf("%v: %v %v", q, yes, err)
+		}
+	}
+}
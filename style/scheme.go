@@ -0,0 +1,65 @@
+package style
+
+import (
+	"9fans.net/go/draw"
+	"regexp"
+)
+
+var (
+	// Dark asks pages for dark styles and draws text without colour
+	// light on dark
+	Dark bool
+
+	// AutoInvert the colours of pages without dark styles in dark mode
+	AutoInvert bool
+)
+
+var reDark = regexp.MustCompile(`prefers-color-scheme\s*:\s*dark|color-scheme\s*:[^;}]*dark`)
+
+// SetDark mode, optionally with automatic colour inversion
+func SetDark(dark, autoInvert bool) {
+	Dark = dark
+	AutoInvert = autoInvert
+	if dark {
+		MediaValues["prefers-color-scheme"] = "dark"
+	} else {
+		MediaValues["prefers-color-scheme"] = "light"
+	}
+}
+
+// Foreground colour of text without colour
+func Foreground() draw.Color {
+	if Dark {
+		return 0xddddddff
+	}
+	return draw.Black
+}
+
+// Background colour of pages
+func Background() draw.Color {
+	if Dark {
+		return 0x1e1e1eff
+	}
+	return draw.White
+}
+
+// DetectDark decides whether colours of the page with the stylesheets
+// csss are inverted. That's the case in dark mode with AutoInvert if
+// none of them has dark styles. AddOnCSS doesn't count.
+func DetectDark(csss []string) (inverted bool) {
+	inverted = Dark && AutoInvert
+	for _, css := range csss {
+		if css != AddOnCSS && reDark.MatchString(css) {
+			inverted = false
+		}
+	}
+	return
+}
+
+// invert the lightness of c keeping hue and saturation
+func invert(c draw.Color) draw.Color {
+	r, g, b := int(c>>24)&0xff, int(c>>16)&0xff, int(c>>8)&0xff
+	off := 255 - max(r, g, b) - min(r, g, b)
+	r, g, b = r+off, g+off, b+off
+	return draw.Color(r<<24|g<<16|b<<8) | c&0xff
+}
@@ -0,0 +1,57 @@
+package style
+
+import (
+	"9fans.net/go/draw"
+	"testing"
+)
+
+func TestInvert(t *testing.T) {
+	for c, exp := range map[draw.Color]draw.Color{
+		draw.White: draw.Black,
+		draw.Black: draw.White,
+		0x0000ff80: 0x0000ff80,
+		0xccccccff: 0x333333ff,
+		0x336699ff: 0x6699ccff,
+	} {
+		if i := invert(c); i != exp {
+			t.Errorf("%x: %x", uint32(c), uint32(i))
+		}
+	}
+}
+
+func TestDetectDark(t *testing.T) {
+	defer SetDark(false, false)
+	m := Map{Declarations: map[string]Declaration{
+		"color":            {Prop: "color", Val: "#cccccc"},
+		"background-color": {Prop: "background-color", Val: "#ffffff"},
+	}}
+	dark := `@media (prefers-color-scheme: dark) { body { color: white; } }`
+	for _, tt := range []struct {
+		dark, invert bool
+		csss         []string
+		color, bg    draw.Color
+		scheme       string
+	}{
+		{false, true, []string{AddOnCSS}, 0xccccccff, draw.White, "light"},
+		{true, false, []string{AddOnCSS}, 0xccccccff, draw.White, "dark"},
+		{true, true, []string{AddOnCSS}, 0x333333ff, draw.Black, "dark"},
+		{true, true, []string{AddOnCSS, dark}, 0xccccccff, draw.White, "dark"},
+	} {
+		SetDark(tt.dark, tt.invert)
+		m.DomTree = pageTree{&Page{Inverted: DetectDark(tt.csss)}}
+		bg, _ := m.backgroundColor()
+		if c := m.Color(); c != tt.color || bg != tt.bg || MediaValues["prefers-color-scheme"] != tt.scheme {
+			t.Errorf("%+v: %x %x", tt, uint32(c), uint32(bg))
+		}
+	}
+	if c := (Map{}).Color(); c != Foreground() || c != 0xddddddff {
+		t.Errorf("%x", uint32(c))
+	}
+
+	// pages laid out later don't change earlier ones
+	a := Map{Declarations: m.Declarations, DomTree: pageTree{&Page{Inverted: DetectDark(nil)}}}
+	b := Map{Declarations: m.Declarations, DomTree: pageTree{&Page{Inverted: DetectDark([]string{dark})}}}
+	if a.Color() != 0x333333ff || b.Color() != 0xccccccff {
+		t.Errorf("%x %x", uint32(a.Color()), uint32(b.Color()))
+	}
+}
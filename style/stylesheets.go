@@ -32,7 +32,7 @@ var MediaValues = map[string]string{
 	"type": "screen",
 	"width": fmt.Sprintf("%vpx", WindowWidth),
 	"orientation": "landscape",
-	"prefers-color-scheme": "dark",
+	"prefers-color-scheme": "light",
 }
 
 const AddOnCSS = `
@@ -59,6 +59,12 @@ address, article, aside, blockquote, details, dialog, dd, div, dl, dt, fieldset,
   color: blue;
   margin-right: 2px;
 }
+
+@media (prefers-color-scheme: dark) {
+  *[href] {
+    color: #8ab4f8;
+  }
+}
 `
 
 func Init(d *duit.DUI) {
@@ -104,16 +110,46 @@ func FetchNodeMap(doc *html.Node, cssText string) (m map[*html.Node]Map, err err
 	return
 }
 
+// FetchUserNodeMap is like FetchNodeMap for user stylesheets
+func FetchUserNodeMap(doc *html.Node, cssText string) (m map[*html.Node]Map, err error) {
+	if m, err = FetchNodeMap(doc, cssText); err != nil {
+		return
+	}
+	for _, mp := range m {
+		for k, d := range mp.Declarations {
+			d.User = true
+			mp.Declarations[k] = d
+		}
+	}
+	return
+}
+
+// smaller is true if d has lower precedence than dd when both apply
+// to the same element. Normal user declarations lose against the
+// page's but important ones win.
 func smaller(d, dd Declaration) bool {
-	if dd.Important {
+	if r, rr := d.rank(), dd.rank(); r != rr {
+		return r < rr
+	} else if dd.Important {
 		return true
-	} else if d.Important {
-		return false
 	} else {
 		return d.Specificity.Less(dd.Specificity)
 	}
 }
 
+// rank of the origin and importance of d in the cascade
+func (d Declaration) rank() int {
+	switch {
+	case d.Important && d.User:
+		return 3
+	case d.Important:
+		return 2
+	case d.User:
+		return 0
+	}
+	return 1
+}
+
 func compile(v string) (cs cascadia.SelectorGroup, err error) {
 	return cascadia.ParseGroup(v)
 }
@@ -191,6 +227,17 @@ type DomTree interface {
 	Rect() image.Rectangle
 	Parent() (p DomTree, ok bool)
 	Style() Map
+	Page() *Page
+}
+
+// Page state shared by the maps of a node tree, so that pages in
+// other tabs are laid out independently
+type Page struct {
+	// Zoom factor of font sizes and lengths
+	Zoom float64
+
+	// Inverted colours, see DetectDark
+	Inverted bool
 }
 
 type Map struct {
@@ -258,14 +305,18 @@ func (cs Map) ApplyChildStyle(ccs Map, copyAll bool) (res Map) {
 				continue
 			}
 		}
+		if !copyAll {
+			v.inherited = true
+		}
 		res.Declarations[k] = v
 	}
-	// overwrite with higher prio child props
+	// overwrite with higher prio child props, values specified on
+	// the child always beat inherited ones
 	for k, d := range ccs.Declarations {
 		if d.Val == "inherit" {
 			continue
 		}
-		if exist, ok := res.Declarations[k]; ok && smaller(d, exist) {
+		if exist, ok := res.Declarations[k]; ok && !exist.inherited && smaller(d, exist) {
 			continue
 		}
 		res.Declarations[k] = d
@@ -329,6 +380,10 @@ func matchClosestFontSize(desired float64, available []int) (closest int) {
 }
 
 func (cs Map) FontSize() float64 {
+	return cs.fontSize() * cs.Zoom()
+}
+
+func (cs Map) fontSize() float64 {
 	fs, ok := cs.Declarations["font-size"]
 	if !ok || fs.Val == "" {
 		return FontBaseSize
@@ -350,6 +405,28 @@ func (cs Map) FontSize() float64 {
 	return f
 }
 
+// Zoom of the page. It's only applied to the page, not to the
+// browser's own widgets whose maps have no DOM.
+func (cs *Map) Zoom() float64 {
+	if p := cs.page(); p != nil && p.Zoom > 0 {
+		return p.Zoom
+	}
+	return 1
+}
+
+// inverted colours of the page
+func (cs *Map) inverted() bool {
+	p := cs.page()
+	return p != nil && p.Inverted
+}
+
+func (cs *Map) page() *Page {
+	if cs == nil || cs.DomTree == nil {
+		return nil
+	}
+	return cs.DomTree.Page()
+}
+
 // FontHeight in lowDPI pixels.
 func (cs Map) FontHeight() float64 {
 	return float64(cs.Font().Height) / float64(dui.Scale(1))
@@ -359,10 +436,13 @@ func (cs Map) Color() draw.Color {
 	if d, ok := cs.Declarations["color"]; ok {
 		if h, ok := colorHex(d.Val); ok {
 			c := draw.Color(h)
+			if cs.inverted() {
+				c = invert(c)
+			}
 			return c
 		}
 	}
-	return draw.Black
+	return Foreground()
 }
 
 func colorHex(propVal string) (c draw.Color, ok bool) {
@@ -630,13 +710,14 @@ func length(cs *Map, l string) (f float64, unit string, err error) {
 
 	switch unit {
 	case "px":
+		f *= cs.Zoom()
 	case "rem":
 		// TODO: use font size from root element
-		f *= FontBaseSize
+		f *= FontBaseSize * cs.Zoom()
 	case "em", "ex":
 		// TODO: distinguish between em and ex
 		if cs == nil {
-			f *= FontBaseSize
+			f *= FontBaseSize * cs.Zoom()
 		} else {
 			f *= cs.FontHeight()
 		}
@@ -660,7 +741,7 @@ func length(cs *Map, l string) (f float64, unit string, err error) {
 		if dui != nil && dui.Display != nil && dui.Display.DPI != 0 {
 			dpi = dui.Display.DPI
 		}
-		f *= float64(dpi) / 25.4
+		f *= float64(dpi) / 25.4 * cs.Zoom()
 	default:
 		return f, unit, fmt.Errorf("unknown suffix: %v", l)
 	}
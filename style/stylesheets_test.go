@@ -5,6 +5,8 @@ import (
 	"github.com/mjl-/duit"
 	"github.com/psilva261/mycel/logger"
 	"golang.org/x/net/html"
+	"image"
+	"os"
 	"strings"
 	"testing"
 )
@@ -265,6 +267,81 @@ func TestSmaller(t *testing.T) {
 	}
 }
 
+func TestSmallerUser(t *testing.T) {
+	author := Declaration{Specificity: [3]int{1, 0, 0}}
+	user := Declaration{User: true}
+	if !smaller(user, author) || smaller(author, user) {
+		t.Fatalf("normal user declaration wins")
+	}
+	author.Important = true
+	user.Important = true
+	if smaller(user, author) || !smaller(author, user) {
+		t.Fatalf("important user declaration loses")
+	}
+}
+
+func TestUserCSS(t *testing.T) {
+	d := t.TempDir()
+	files := map[string]string{
+		"user.css":                "p { color: black !important; font-size: 20px; }",
+		"css/example.com.css":     "h2 { color: red; }",
+		"css/www.example.com.css": "#foo { color: green !important; }",
+	}
+	if err := os.Mkdir(d+"/css", 0700); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for fn, css := range files {
+		if err := os.WriteFile(d+"/"+fn, []byte(css), 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	SetUserDir(d)
+	defer SetUserDir("")
+	if csss := UserCSS("www.example.com"); len(csss) != 3 || csss[2] != files["css/www.example.com.css"] {
+		t.Fatalf("%+v", csss)
+	}
+	if csss := UserCSS("example.org"); len(csss) != 1 {
+		t.Fatalf("%+v", csss)
+	}
+
+	doc, err := html.Parse(strings.NewReader(`<h2 id="foo" style="color: blue !important">a</h2><h2 id="bar" style="color: blue">b</h2><p style="color: blue; font-size: 12px">c</p>`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	m := make(map[*html.Node]Map)
+	for _, css := range UserCSS("www.example.com") {
+		nm, err := FetchUserNodeMap(doc, css)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		MergeNodeMaps(m, nm)
+	}
+	for _, tt := range []struct {
+		tag, id, prop, val string
+	}{
+		{"h2", "foo", "color", "green"},
+		{"h2", "bar", "color", "blue"},
+		{"p", "", "color", "black"},
+		{"p", "", "font-size", "12px"},
+	} {
+		var n *html.Node
+		var f func(*html.Node)
+		f = func(c *html.Node) {
+			if c.Type == html.ElementNode && c.Data == tt.tag && (tt.id == "" || c.Attr[0].Val == tt.id) {
+				n = c
+			}
+			for cc := c.FirstChild; cc != nil; cc = cc.NextSibling {
+				f(cc)
+			}
+		}
+		f(doc)
+		res := m[n].ApplyChildStyle(NewMap(n), true)
+		if v := res.Declarations[tt.prop].Val; v != tt.val {
+			t.Errorf("%+v: %v", tt, v)
+		}
+	}
+}
+
 func TestApplyChildStyleInherit(t *testing.T) {
 	parent := Map{
 		Declarations: make(map[string]Declaration),
@@ -410,6 +487,37 @@ func TestLength(t *testing.T) {
 	}
 }
 
+// pageTree is a DomTree without parent on the page p
+type pageTree struct {
+	p *Page
+}
+
+func (pageTree) Rect() image.Rectangle   { return image.Rectangle{} }
+func (pageTree) Parent() (DomTree, bool) { return nil, false }
+func (pageTree) Style() Map              { return Map{} }
+func (pt pageTree) Page() *Page          { return pt.p }
+
+func TestZoom(t *testing.T) {
+	m := Map{DomTree: pageTree{&Page{Zoom: 2}}}
+	for l, px := range map[string]float64{"17px": 34, "10rem": 220, "10vw": 128} {
+		if f, _, err := length(&m, l); err != nil || f != px {
+			t.Fatalf("%v: %v %v", l, f, err)
+		}
+	}
+	// other pages are not affected
+	o := Map{DomTree: pageTree{&Page{Zoom: 1}}}
+	if f, _, _ := length(&o, "17px"); f != 17 {
+		t.Fatalf("other page is zoomed: %v", f)
+	}
+	w := Map{Declarations: map[string]Declaration{"font-size": {Prop: "font-size", Val: "14px"}}}
+	if fs := w.FontSize(); fs != 14 {
+		t.Fatalf("widgets are zoomed: %v", fs)
+	}
+	if f, _, _ := length(&w, "10px"); f != 10 {
+		t.Fatalf("widgets are zoomed: %v", f)
+	}
+}
+
 func TestTlbr(tt *testing.T) {
 	cases := map[string]duit.Space{
 		"1px 2px 3px 4px": duit.Space{1, 2, 3, 4},
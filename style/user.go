@@ -0,0 +1,50 @@
+package style
+
+import (
+	"github.com/psilva261/mycel/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	userMu  sync.RWMutex
+	userDir string
+)
+
+// SetUserDir to read user.css and the per domain overrides in
+// css/<domain>.css from
+func SetUserDir(d string) {
+	userMu.Lock()
+	defer userMu.Unlock()
+	userDir = d
+}
+
+// UserCSS for host: user.css followed by the overrides of the parent
+// domains and host itself, so that more specific files come later.
+// The files are read on every call to pick up changes on reload.
+func UserCSS(host string) (csss []string) {
+	userMu.RLock()
+	d := userDir
+	userMu.RUnlock()
+	if d == "" {
+		return
+	}
+	fns := []string{filepath.Join(d, "user.css")}
+	labels := strings.Split(strings.ToLower(host), ".")
+	for i := len(labels) - 1; i >= 0 && host != ""; i-- {
+		fns = append(fns, filepath.Join(d, "css", strings.Join(labels[i:], ".")+".css"))
+	}
+	for _, fn := range fns {
+		buf, err := os.ReadFile(fn)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			log.Errorf("user css: %v", err)
+			continue
+		}
+		csss = append(csss, string(buf))
+	}
+	return
+}